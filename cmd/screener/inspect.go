@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/root4loot/goutils/log"
+	"github.com/root4loot/screener/pkg/screener"
+)
+
+const inspectUsage = `USAGE:
+  screener inspect [options] <image>...
+
+Show the capture metadata embedded in screenshots saved by screener.
+
+OPTIONS:
+  -j,   --json                   print the metadata of each image as a JSON line
+`
+
+// inspect prints the metadata embedded in each image in args and returns the
+// exit code
+func inspect(args []string) int {
+	var asJSON bool
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	flags.BoolVar(&asJSON, "json", false, "")
+	flags.BoolVar(&asJSON, "j", false, "")
+	flags.Usage = func() {
+		fmt.Print(inspectUsage)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Print(inspectUsage)
+		return 1
+	}
+
+	code, printed := 0, 0
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Error(err)
+			code = 1
+			continue
+		}
+
+		_, texts, err := screener.ReadImageMetadata(data)
+		if err != nil {
+			log.Errorf("Error reading %s: %v", path, err)
+			code = 1
+			continue
+		}
+		if len(texts) == 0 {
+			log.Warnf("No metadata found in %s", path)
+			continue
+		}
+
+		if asJSON {
+			fields := map[string]string{"File": path}
+			for _, kv := range texts {
+				fields[kv[0]] = kv[1]
+			}
+			b, _ := json.Marshal(fields)
+			fmt.Println(string(b))
+			continue
+		}
+
+		if printed++; printed > 1 {
+			fmt.Println()
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, path)
+		for _, kv := range texts {
+			fmt.Fprintf(tw, "  %s\t%s\n", kv[0], kv[1])
+		}
+		tw.Flush()
+	}
+
+	return code
+}
@@ -3,20 +3,27 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/ed25519"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/root4loot/goutils/fileutil"
 	"github.com/root4loot/goutils/log"
 	"github.com/root4loot/goutils/urlutil"
 	"github.com/root4loot/screener/pkg/screener"
+	"github.com/root4loot/screener/pkg/screener/resultsdb"
 )
 
 const (
@@ -24,13 +31,22 @@ const (
 	version = "0.1.0"
 	usage   = `USAGE:
   screener [options] (-t <target> | -l <targets.txt>)
+  screener inspect <image>...
+  screener verify [--key <public key>] <manifest>
 
 INPUT:
-  -t, --target                   target input (domain, IP, URL)
-  -l, --list                     input file with list of targets (one per line)
+  -t,   --target                 target input (domain, IP, URL, CIDR, IP range)
+  -l,   --list                   input file with list of targets (one per line, or sitemap URL)
+  -pt,  --ports                  ports to probe on each target (comma separated)         (Example: 80,443,8000-8100)
+  -if,  --input-format           format of -l and stdin input                            (Default: plain)
+                                 Options: plain, nmap, masscan, httpx, burp, zap, sitemap
+  -me,  --max-expansion          maximum URLs generated from a single target             (Default: 65536)
+  -mu,  --max-urls-per-host      maximum URLs per host from imported input               (Default: unlimited)
+  -re,  --resume                 resume from a state file, skipping completed targets
 
 CONFIGURATIONS:
   -ad,  --avoid-duplicates       prevent saving duplicate outputs                        (Default: false)
+  -bp,  --block-private          block private, loopback and reserved destinations       (Default: false)
   -c,   --concurrency            number of concurrent operations                         (Default: 10)
   -cf,  --capture-full           capture entire content                                  (Default: false)
   -ch,  --capture-height         output height                                           (Default: 768)
@@ -41,19 +57,73 @@ CONFIGURATIONS:
                                  Applicable only when --avoid-duplicates is enabled. Outputs
                                  with a similarity score greater than or equal to this value
                                  will be considered duplicates and will not be saved.
+  -gp,  --grace-period           time given to captures in flight when stopping          (Default: 10s)
+  -hc,  --host-concurrency       maximum concurrent captures per host                    (Default: unlimited)
+  -hr,  --host-rate-limit        maximum captures per second per host                    (Default: unlimited)
   -isc, --ignore-status-codes    ignore specific status codes (comma separated)          (Default: 204, 301, 302, 304, 401, 407)
   -nr,  --ignore-redirects       do not follow redirects                                 (Default: false)
   -p,   --proxy                  HTTP/SOCKS5 proxy server                                (Example: 127.0.0.1:8080)
   -r,   --resolvers              custom DNS resolvers (comma separated)                  (Default: system resolvers)
   -rf,  --resolver-file          file containing DNS resolvers (one per line)
+                                 Resolvers may be plain addresses or udp://, tcp://, tls://
+                                 (DNS-over-TLS) and https:// (DNS-over-HTTPS) URIs.
+  -rdn, --record-dns             record DNS answers when using the system resolver       (Default: false)
+  -ra,  --retry-attempts         total attempts per target for transient failures        (Default: 1)
+  -rb,  --retry-backoff          initial backoff between attempts, doubled each retry    (Default: 1s)
+  -rmb, --retry-max-backoff      maximum backoff between attempts                        (Default: 30s)
+  -ro,  --retry-on               failure categories to retry (comma separated)           (Default: reset,timeout,5xx,crash)
+  -rl,  --rate-limit             maximum captures per second across all hosts            (Default: unlimited)
+  -rd,  --run-deadline           stop starting new captures after this duration          (Example: 30m)
   -rce, --respect-cert-err       respect certificate errors                              (Default: false)
+  -sf,  --scope-file             file with scope rules (one per line)
+                                 Rules may be hosts, *.domain wildcards, CIDRs, re:<regex>
+                                 URL patterns, or any of these prefixed with ! to exclude.
+  -ss,  --scope-subresources     also block out of scope subresource requests            (Default: false)
   -to,  --timeout                screenshot timeout                                      (Default: 15 seconds)
   -ua,  --user-agent             specify user agent                                      (Default: Chrome UA)
   -uh,  --use-http2              use HTTP2                                               (Default: false)
+  -wc,  --wildcard               skip or tag hosts matching a wildcard DNS record        (Options: skip, tag)
 
 OUTPUT:
-  -o,   --outfolder              save outputs to specified folder                        (Default: screenshots/)
+  -o,   --output                 save outputs to a folder, an archive or S3              (Default: screenshots/)
+                                 Archives end in .zip, .tar, .tar.gz or .tgz. s3://bucket/prefix
+                                 uses AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION and
+                                 AWS_ENDPOINT_URL_S3 for S3-compatible services.
   -nt,  --no-text                do not add text to output images                        (Default: false)
+  -nm,  --no-metadata            do not embed capture metadata in output images          (Default: false)
+  -af,  --annotate-fields        fields added to the image (comma separated)             (Default: origin)
+                                 Options: origin, url, landing_url, status, title, ip, timestamp,
+                                 resolver
+  -ap,  --annotate-position      where the text is added: top or bottom                  (Default: bottom)
+  -afs, --annotate-font-size     font size of the text                                   (Default: 14)
+  -aff, --annotate-font          font files for the text (comma separated)               (Default: Roboto)
+                                 Characters missing from these fonts fall back to Roboto, then to
+                                 fonts installed on the system.
+  -afc, --annotate-color         text and border color                                   (Default: #000000)
+  -abc, --annotate-background    background color of the text                            (Default: #ffffff)
+  -tw,  --thumbnail-width        save a thumbnail of this width next to each output      (Example: 320)
+  -ft,  --filename-template      template for output file names, / creates directories
+                                 Placeholders: {scheme}, {host}, {port}, {path}, {query_hash},
+                                 {status}, {date}, {viewport}
+                                 (Default: {scheme}_{host}_{port}_{path}_{query_hash})
+  -hd,  --host-dirs              save outputs in a subdirectory per host                 (Default: false)
+  -oc,  --on-collision           when a file name is already used or stored              (Default: suffix)
+                                 Options: suffix, overwrite, skip
+  -csv, --csv                    write a CSV report of all targets to file
+  -md,  --markdown               write a Markdown report with image links to file
+  -rc,  --report-columns         report columns (comma separated)                        (Default: url,status,title,image)
+                                 Options: url, landing_url, host, status, title, error, attempts,
+                                 captured_at, duration, image, thumbnail
+  -rs,  --report-sort            sort reports by host or status                          (Default: capture order)
+  -mo,  --montage                write contact sheets of screenshots with this grid      (Example: 4x3)
+  -mc,  --montage-cluster        one cluster of similar screenshots per contact sheet    (Default: false)
+  -np,  --no-progress            do not show the progress line on stderr                 (Default: false)
+        --db                     record every capture in a SQLite database
+        --db-images              also store screenshots in the database                  (Default: false)
+  -mf,  --manifest               write a signed manifest of all saved files, reports and
+                                 database with their hashes
+  -mk,  --manifest-key           Ed25519 key signing the manifest, created if missing    (Default: screener.key)
+  -st,  --state-file             file listing completed targets when interrupted         (Default: screener.state)
         --debug                  enable debug mode
         --version                display version
 `
@@ -66,10 +136,44 @@ type cli struct {
 	Infile               string
 	SaveScreenshotFolder string
 	NoImprint            bool
+	NoMetadata           bool
 	AvoidDuplicates      bool
 	DuplicateThreshold   int
 	Debug                bool
 	IgnoreStatusCodes    []int
+	Expand               screener.ExpandOptions
+	InputFormat          string
+	MaxURLsPerHost       int
+	RunDeadline          time.Duration
+	GracePeriod          time.Duration
+	StateFile            string
+	Database             string
+	DatabaseImages       bool
+	ManifestFile         string
+	ManifestKey          string
+	CSVReport            string
+	MarkdownReport       string
+	Report               screener.ReportOptions
+	Montage              screener.MontageOptions
+	NoProgress           bool
+	FilenameTemplate     string
+	ThumbnailWidth       int
+	AnnotatePosition     string
+	AnnotateFontSize     float64
+	AnnotateFont         string
+	AnnotateColor        string
+	AnnotateBackground   string
+	annotation           *screener.Annotation
+	HostDirs             bool
+	OnCollision          string
+	layout               *screener.Layout
+	storage              screener.Storage
+	db                   *resultsdb.DB
+	manifest             *screener.Manifest
+	signingKey           ed25519.PrivateKey
+	state                runState
+	progress             *progress
+	capture              func(context.Context, *url.URL) (*screener.Result, error) // replaces the browser in tests
 }
 
 func NewCLIOptions() *cli {
@@ -80,6 +184,17 @@ func NewCLIOptions() *cli {
 		AvoidDuplicates:      false,
 		DuplicateThreshold:   96,
 		IgnoreStatusCodes:    []int{},
+		Expand:               screener.ExpandOptions{MaxExpansion: screener.DefaultMaxExpansion},
+		InputFormat:          screener.FormatPlain,
+		GracePeriod:          10 * time.Second,
+		StateFile:            "screener.state",
+		ManifestKey:          "screener.key",
+		FilenameTemplate:     screener.DefaultFilenameTemplate,
+		AnnotatePosition:     screener.PositionBottom,
+		AnnotateFontSize:     14,
+		AnnotateColor:        "#000000",
+		AnnotateBackground:   "#ffffff",
+		OnCollision:          screener.CollisionSuffix,
 	}
 }
 
@@ -93,38 +208,334 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "inspect":
+			os.Exit(inspect(os.Args[2:]))
+		case "verify":
+			os.Exit(verify(os.Args[2:]))
+		}
+	}
+
 	cli := NewCLI()
 	cli.parseFlags()
 
-	targetChannel := make(chan string)
+	// ctx stops feeding targets, captureCtx cancels captures in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cli.RunDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cli.RunDeadline)
+		defer cancel()
+	}
+
+	captureCtx, cancelCaptures := context.WithCancel(context.Background())
+	defer cancelCaptures()
+
+	targetChannel := make(chan screener.Target)
 	done := make(chan struct{})
 
-	go processTarget(cli.worker, cli.Concurrency, targetChannel, done)
+	worker := func(target screener.Target) error {
+		if cli.CaptureOptions.State.Has(target.URL) {
+			log.Debugf("Skipping %s as it was completed in a previous run", target.URL)
+			cli.progress.record(outcomeResumed, 0)
+			return nil
+		}
+
+		cli.state.start()
+		outcome := cli.worker(captureCtx, target)
+		// Targets that failed for reasons that may pass are tried again on resume
+		completed := captureCtx.Err() == nil && !transientOutcomes[outcome]
+		cli.state.finish(target.URL, completed)
+
+		if completed {
+			if err := cli.CaptureOptions.State.Add(target.URL); err != nil {
+				log.Warnf("Error recording %s in state file: %v", target.URL, err)
+			}
+		}
+		return nil
+	}
+
+	cli.progress = newProgress(os.Stderr, !cli.NoProgress)
+	if !cli.NoProgress && cli.progress.tty {
+		log.WithFields(nil).Logger.SetOutput(cli.progress)
+	}
+	go cli.progress.run()
+
+	go processTarget(ctx, worker, cli.Concurrency, targetChannel, done)
+	go cli.shutdownOnStop(ctx, stop, cancelCaptures, done)
 
-	processTargets(cli, targetChannel)
+	cli.processTargets(ctx, targetChannel)
+	cli.progress.finishInput()
 	close(targetChannel)
 	<-done
+
+	cli.progress.close()
+	log.WithFields(nil).Logger.SetOutput(os.Stderr)
+	cli.progress.summary(os.Stderr)
+
+	cli.writeMontages()
+
+	if err := cli.storage.Close(); err != nil {
+		log.Errorf("Error closing output %s: %v", cli.SaveScreenshotFolder, err)
+	}
+	if err := cli.db.Close(); err != nil {
+		log.Errorf("Error closing database %s: %v", cli.Database, err)
+	} else if cli.Database != "" {
+		cli.addToManifest(screener.ArtifactDatabase, cli.Database)
+	}
+
+	cli.writeReports()
+	cli.writeManifest()
+
+	if ctx.Err() != nil {
+		cli.writeState()
+	}
+	cli.CaptureOptions.State.Close()
+
+	cli.logResolverStats()
+}
+
+// shutdownOnStop waits for the run to be stopped by a signal or the run
+// deadline, then gives in-flight captures the grace period to finish before
+// cancelling them. A second signal exits immediately.
+func (cli *cli) shutdownOnStop(ctx context.Context, stop context.CancelFunc, cancelCaptures context.CancelFunc, done <-chan struct{}) {
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	// Restore default signal handling so a second Ctrl-C kills the process
+	stop()
+
+	reason := "Interrupted"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = fmt.Sprintf("Run deadline of %v reached", cli.RunDeadline)
+	}
+
+	inFlight, _ := cli.state.counts()
+	if inFlight > 0 {
+		log.Warnf("%s, waiting up to %v for %d captures in flight", reason, cli.GracePeriod, inFlight)
+	} else {
+		log.Warnf("%s, stopping", reason)
+	}
+
+	timer := time.NewTimer(cli.GracePeriod)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		log.Warnf("Grace period expired, cancelling captures in flight")
+		cancelCaptures()
+	}
 }
 
-func processTargets(cli *cli, targetChannel chan<- string) {
+// writeMontages stores contact sheets of the saved screenshots in a
+// montages folder of the output
+func (cli *cli) writeMontages() {
+	if cli.Montage.Columns == 0 {
+		return
+	}
+
+	resultsMutex.Lock()
+	defer resultsMutex.Unlock()
+
+	montages, err := screener.BuildMontages(results, cli.Montage)
+	if err != nil {
+		log.Errorf("Error building contact sheets: %v", err)
+		return
+	}
+
+	for _, m := range montages {
+		uri, err := cli.storage.Put(context.Background(), "montages/"+m.Name(), m.Image, nil)
+		if err != nil {
+			log.Errorf("Error saving contact sheet: %v", err)
+			return
+		}
+		log.Infof("Contact sheet of %d screenshots saved %q", len(m.URLs), uri)
+	}
+}
+
+// writeManifest signs the manifest of the files saved during the run and
+// writes it out
+func (cli *cli) writeManifest() {
+	if cli.manifest == nil {
+		return
+	}
+
+	signed, err := cli.manifest.Sign(cli.signingKey)
+	if err != nil {
+		log.Errorf("Error signing manifest: %v", err)
+		return
+	}
+	if err := os.WriteFile(cli.ManifestFile, signed, 0o644); err != nil {
+		log.Errorf("Error writing manifest: %v", err)
+		return
+	}
+	log.Infof("Signed manifest of %d files written to %s", len(cli.manifest.Artifacts), cli.ManifestFile)
+}
+
+// addToManifest adds a file written outside the output to the manifest, so
+// that it is covered by the signature too
+func (cli *cli) addToManifest(kind, path string) {
+	if cli.manifest == nil {
+		return
+	}
+	if err := cli.manifest.AddFile(kind, path, filepath.Dir(cli.ManifestFile)); err != nil {
+		log.Errorf("Error adding %s to manifest: %v", path, err)
+	}
+}
+
+// writeReports writes the CSV and Markdown reports of all targets processed.
+// Image links are made relative to the folder each report is written to.
+func (cli *cli) writeReports() {
+	reports := []struct {
+		path  string
+		write func(io.Writer, []screener.Result, screener.ReportOptions) error
+	}{
+		{cli.CSVReport, screener.WriteCSV},
+		{cli.MarkdownReport, screener.WriteMarkdown},
+	}
+
+	resultsMutex.Lock()
+	defer resultsMutex.Unlock()
+
+	for _, report := range reports {
+		if report.path == "" {
+			continue
+		}
+
+		opts := cli.Report
+		opts.ImageBase = filepath.Dir(report.path)
+
+		f, err := os.Create(report.path)
+		if err != nil {
+			log.Errorf("Error creating report: %v", err)
+			continue
+		}
+		err = report.write(f, reported, opts)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			log.Errorf("Error writing report %s: %v", report.path, err)
+			continue
+		}
+		log.Infof("Report of %d targets written to %s", len(reported), report.path)
+		cli.addToManifest(screener.ArtifactReport, report.path)
+	}
+}
+
+// writeState saves the completed targets of an interrupted run. When
+// resuming, they have already been appended to the resumed state file.
+func (cli *cli) writeState() {
+	_, completed := cli.state.counts()
+	if state := cli.CaptureOptions.State; state != nil {
+		log.Infof("Completed %d targets, state saved to %s", completed, state.Path())
+		return
+	}
+
+	if err := cli.state.write(cli.StateFile); err != nil {
+		log.Errorf("Error writing state file: %v", err)
+		return
+	}
+	log.Infof("Completed %d targets, state written to %s", completed, cli.StateFile)
+}
+
+func (cli *cli) logResolverStats() {
+	for _, st := range cli.ResolverStats() {
+		log.Infof("[resolver=%s] queries=%d failures=%d avg-latency=%v benched=%d",
+			st.Resolver, st.Queries, st.Failures, st.AvgLatency.Round(time.Millisecond), st.Benched)
+	}
+}
+
+func (cli *cli) processTargets(ctx context.Context, targetChannel chan<- screener.Target) {
 	if cli.hasStdin() {
-		processStdinTargets(targetChannel)
+		cli.processStdinTargets(ctx, targetChannel)
 	}
 
 	if cli.hasInfile() {
-		processFileTargets(cli.Infile, targetChannel)
+		cli.processFileTargets(ctx, cli.Infile, targetChannel)
 	}
 
 	if cli.hasTarget() {
-		processDirectTargets(cli.TargetURL, targetChannel)
+		cli.processDirectTargets(ctx, cli.TargetURL, targetChannel)
 	}
 }
 
-func processStdinTargets(targetChannel chan<- string) {
+// send queues target, returning false once the run has been stopped
+func (cli *cli) send(ctx context.Context, targetChannel chan<- screener.Target, target screener.Target) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	select {
+	case targetChannel <- target:
+		cli.progress.queue()
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sendTarget expands CIDRs, IP ranges and ports in target and queues the resulting URLs
+func (cli *cli) sendTarget(ctx context.Context, target string, targetChannel chan<- screener.Target) bool {
+	urls, err := screener.ExpandTarget(target, cli.Expand)
+	if err != nil {
+		log.Errorf("%v", err)
+		return ctx.Err() == nil
+	}
+
+	expanded := len(urls) != 1 || urls[0] != strings.TrimSpace(target)
+	for _, u := range urls {
+		if !cli.send(ctx, targetChannel, screener.Target{URL: u, Expanded: expanded}) {
+			return false
+		}
+	}
+	return true
+}
+
+// sendParsedTargets queues the targets parsed from scanner, proxy or sitemap input
+func (cli *cli) sendParsedTargets(ctx context.Context, r io.Reader, targetChannel chan<- screener.Target) error {
+	targets, err := screener.ParseTargets(cli.InputFormat, r)
+	if err != nil {
+		return err
+	}
+
+	cli.sendTargets(ctx, targets, targetChannel)
+	return nil
+}
+
+// sendTargets dedupes targets, applies the per-host limit and queues them
+func (cli *cli) sendTargets(ctx context.Context, targets []screener.Target, targetChannel chan<- screener.Target) {
+	targets = screener.LimitPerHost(screener.DedupeTargets(targets), cli.MaxURLsPerHost)
+
+	log.Debugf("Parsed %d targets from %s input", len(targets), cli.InputFormat)
+	for _, target := range targets {
+		if !cli.send(ctx, targetChannel, target) {
+			return
+		}
+	}
+}
+
+func (cli *cli) processStdinTargets(ctx context.Context, targetChannel chan<- screener.Target) {
+	if cli.InputFormat != screener.FormatPlain {
+		if err := cli.sendParsedTargets(ctx, os.Stdin, targetChannel); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
+			close(targetChannel)
+			os.Exit(1)
+		}
+		return
+	}
+
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
 		for _, target := range strings.Fields(scanner.Text()) {
-			targetChannel <- target
+			if !cli.sendTarget(ctx, target, targetChannel) {
+				return
+			}
 		}
 	}
 
@@ -135,7 +546,32 @@ func processStdinTargets(targetChannel chan<- string) {
 	}
 }
 
-func processFileTargets(infile string, targetChannel chan<- string) {
+func (cli *cli) processFileTargets(ctx context.Context, infile string, targetChannel chan<- screener.Target) {
+	if cli.InputFormat == screener.FormatSitemap && urlutil.HasScheme(infile) {
+		targets, err := screener.FetchSitemap(infile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading sitemap: %v\n", err)
+			close(targetChannel)
+			os.Exit(1)
+		}
+		cli.sendTargets(ctx, targets, targetChannel)
+		return
+	}
+
+	if cli.InputFormat != screener.FormatPlain {
+		f, err := os.Open(infile)
+		if err == nil {
+			defer f.Close()
+			err = cli.sendParsedTargets(ctx, f, targetChannel)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			close(targetChannel)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fileTargets, err := fileutil.ReadFile(infile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
@@ -143,50 +579,57 @@ func processFileTargets(infile string, targetChannel chan<- string) {
 		os.Exit(1)
 	}
 	for _, target := range fileTargets {
-		targetChannel <- target
+		if !cli.sendTarget(ctx, target, targetChannel) {
+			return
+		}
 	}
 }
 
-func processDirectTargets(targetURL string, targetChannel chan<- string) {
-	if strings.Contains(targetURL, ",") {
-		targets := strings.Split(targetURL, ",")
-		for _, target := range targets {
-			targetChannel <- target
+func (cli *cli) processDirectTargets(ctx context.Context, targetURL string, targetChannel chan<- screener.Target) {
+	for _, target := range strings.Split(targetURL, ",") {
+		if !cli.sendTarget(ctx, target, targetChannel) {
+			return
 		}
-	} else {
-		targetChannel <- targetURL
 	}
 }
 
-func processTarget(worker func(string) error, concurrency int, targetChannel <-chan string, done chan struct{}) {
+// processTarget runs worker on every target with at most concurrency workers
+// at a time, and closes done once the channel is closed and all workers have
+// finished. Once ctx is done, remaining targets are drained without being
+// processed while in-flight workers are left to finish.
+func processTarget(ctx context.Context, worker func(screener.Target) error, concurrency int, targetChannel <-chan screener.Target, done chan<- struct{}) {
+	defer close(done)
+
 	sem := make(chan struct{}, concurrency)
 	var wg sync.WaitGroup
-	timeout := time.AfterFunc(16*time.Second, func() {
-		close(done)
-	})
 
 	for target := range targetChannel {
-		sem <- struct{}{}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		if ctx.Err() != nil {
+			<-sem
+			continue
+		}
+
 		wg.Add(1)
-		go func(t string) {
+		go func(t screener.Target) {
 			defer func() { <-sem }()
 			defer wg.Done()
 			if err := worker(t); err != nil {
-				log.Errorf("Error processing target %s: %v", t, err)
+				log.Errorf("Error processing target %s: %v", t.URL, err)
 			}
-			timeout.Reset(16 * time.Second)
 		}(target)
 	}
 
-	go func() {
-		wg.Wait()
-		timeout.Stop()
-		close(done)
-	}()
+	wg.Wait()
 }
 func (cli *cli) parseFlags() {
 	var help, ver, debug bool
-	var ignoreStatusCodes, customResolvers, resolverFile string
+	var ignoreStatusCodes, customResolvers, resolverFile, ports, scopeFile, retryOn, resume, reportColumns, montage, annotateFields string
 
 	options := NewCLIOptions()
 	captureOptions := screener.NewOptions()
@@ -196,10 +639,66 @@ func (cli *cli) parseFlags() {
 	flag.StringVar(&cli.TargetURL, "t", "", "")
 	flag.StringVar(&cli.Infile, "l", "", "")
 	flag.StringVar(&cli.Infile, "list", "", "")
+	flag.StringVar(&ports, "ports", "", "")
+	flag.StringVar(&ports, "pt", "", "")
+	flag.IntVar(&cli.Expand.MaxExpansion, "max-expansion", options.Expand.MaxExpansion, "")
+	flag.IntVar(&cli.Expand.MaxExpansion, "me", options.Expand.MaxExpansion, "")
+	flag.StringVar(&cli.InputFormat, "input-format", options.InputFormat, "")
+	flag.StringVar(&cli.InputFormat, "if", options.InputFormat, "")
+	flag.IntVar(&cli.MaxURLsPerHost, "max-urls-per-host", options.MaxURLsPerHost, "")
+	flag.IntVar(&cli.MaxURLsPerHost, "mu", options.MaxURLsPerHost, "")
+	flag.DurationVar(&cli.RunDeadline, "run-deadline", options.RunDeadline, "")
+	flag.DurationVar(&cli.RunDeadline, "rd", options.RunDeadline, "")
+	flag.DurationVar(&cli.GracePeriod, "grace-period", options.GracePeriod, "")
+	flag.DurationVar(&cli.GracePeriod, "gp", options.GracePeriod, "")
+	flag.StringVar(&cli.StateFile, "state-file", options.StateFile, "")
+	flag.StringVar(&cli.StateFile, "st", options.StateFile, "")
+	flag.StringVar(&cli.Database, "db", options.Database, "")
+	flag.BoolVar(&cli.DatabaseImages, "db-images", options.DatabaseImages, "")
+	flag.StringVar(&cli.ManifestFile, "manifest", options.ManifestFile, "")
+	flag.StringVar(&cli.ManifestFile, "mf", options.ManifestFile, "")
+	flag.StringVar(&cli.ManifestKey, "manifest-key", options.ManifestKey, "")
+	flag.StringVar(&cli.ManifestKey, "mk", options.ManifestKey, "")
+	flag.StringVar(&cli.CSVReport, "csv", options.CSVReport, "")
+	flag.StringVar(&cli.MarkdownReport, "markdown", options.MarkdownReport, "")
+	flag.StringVar(&cli.MarkdownReport, "md", options.MarkdownReport, "")
+	flag.StringVar(&reportColumns, "report-columns", "", "")
+	flag.StringVar(&reportColumns, "rc", "", "")
+	flag.StringVar(&cli.Report.SortBy, "report-sort", options.Report.SortBy, "")
+	flag.StringVar(&cli.Report.SortBy, "rs", options.Report.SortBy, "")
+	flag.StringVar(&montage, "montage", "", "")
+	flag.StringVar(&montage, "mo", "", "")
+	flag.BoolVar(&cli.Montage.GroupBySimilarity, "montage-cluster", options.Montage.GroupBySimilarity, "")
+	flag.BoolVar(&cli.Montage.GroupBySimilarity, "mc", options.Montage.GroupBySimilarity, "")
+	flag.StringVar(&annotateFields, "annotate-fields", "", "")
+	flag.StringVar(&annotateFields, "af", "", "")
+	flag.StringVar(&cli.AnnotatePosition, "annotate-position", options.AnnotatePosition, "")
+	flag.StringVar(&cli.AnnotatePosition, "ap", options.AnnotatePosition, "")
+	flag.Float64Var(&cli.AnnotateFontSize, "annotate-font-size", options.AnnotateFontSize, "")
+	flag.Float64Var(&cli.AnnotateFontSize, "afs", options.AnnotateFontSize, "")
+	flag.StringVar(&cli.AnnotateFont, "annotate-font", options.AnnotateFont, "")
+	flag.StringVar(&cli.AnnotateFont, "aff", options.AnnotateFont, "")
+	flag.StringVar(&cli.AnnotateColor, "annotate-color", options.AnnotateColor, "")
+	flag.StringVar(&cli.AnnotateColor, "afc", options.AnnotateColor, "")
+	flag.StringVar(&cli.AnnotateBackground, "annotate-background", options.AnnotateBackground, "")
+	flag.StringVar(&cli.AnnotateBackground, "abc", options.AnnotateBackground, "")
+	flag.IntVar(&cli.ThumbnailWidth, "thumbnail-width", options.ThumbnailWidth, "")
+	flag.IntVar(&cli.ThumbnailWidth, "tw", options.ThumbnailWidth, "")
+	flag.BoolVar(&cli.NoProgress, "no-progress", options.NoProgress, "")
+	flag.BoolVar(&cli.NoProgress, "np", options.NoProgress, "")
+	flag.StringVar(&cli.FilenameTemplate, "filename-template", options.FilenameTemplate, "")
+	flag.StringVar(&cli.FilenameTemplate, "ft", options.FilenameTemplate, "")
+	flag.BoolVar(&cli.HostDirs, "host-dirs", options.HostDirs, "")
+	flag.BoolVar(&cli.HostDirs, "hd", options.HostDirs, "")
+	flag.StringVar(&cli.OnCollision, "on-collision", options.OnCollision, "")
+	flag.StringVar(&cli.OnCollision, "oc", options.OnCollision, "")
+	flag.StringVar(&resume, "resume", "", "")
+	flag.StringVar(&resume, "re", "", "")
 
 	// CONFIGURATIONS
 	flag.IntVar(&cli.Concurrency, "concurrency", options.Concurrency, "")
 	flag.IntVar(&cli.Concurrency, "c", options.Concurrency, "")
+	flag.StringVar(&cli.SaveScreenshotFolder, "output", options.SaveScreenshotFolder, "")
 	flag.StringVar(&cli.SaveScreenshotFolder, "outfolder", options.SaveScreenshotFolder, "")
 	flag.StringVar(&cli.SaveScreenshotFolder, "o", options.SaveScreenshotFolder, "")
 	flag.BoolVar(&cli.AvoidDuplicates, "avoid-duplicates", options.AvoidDuplicates, "")
@@ -212,6 +711,24 @@ func (cli *cli) parseFlags() {
 	flag.StringVar(&customResolvers, "r", "", "")
 	flag.StringVar(&resolverFile, "resolver-file", "", "")
 	flag.StringVar(&resolverFile, "rf", "", "")
+	flag.StringVar(&scopeFile, "scope-file", "", "")
+	flag.StringVar(&scopeFile, "sf", "", "")
+	flag.BoolVar(&cli.CaptureOptions.BlockPrivate, "block-private", captureOptions.BlockPrivate, "")
+	flag.BoolVar(&cli.CaptureOptions.BlockPrivate, "bp", captureOptions.BlockPrivate, "")
+	flag.Float64Var(&cli.CaptureOptions.RateLimit, "rate-limit", captureOptions.RateLimit, "")
+	flag.Float64Var(&cli.CaptureOptions.RateLimit, "rl", captureOptions.RateLimit, "")
+	flag.Float64Var(&cli.CaptureOptions.HostRateLimit, "host-rate-limit", captureOptions.HostRateLimit, "")
+	flag.Float64Var(&cli.CaptureOptions.HostRateLimit, "hr", captureOptions.HostRateLimit, "")
+	flag.IntVar(&cli.CaptureOptions.HostConcurrency, "host-concurrency", captureOptions.HostConcurrency, "")
+	flag.IntVar(&cli.CaptureOptions.HostConcurrency, "hc", captureOptions.HostConcurrency, "")
+	flag.IntVar(&cli.CaptureOptions.Retry.MaxAttempts, "retry-attempts", captureOptions.Retry.MaxAttempts, "")
+	flag.IntVar(&cli.CaptureOptions.Retry.MaxAttempts, "ra", captureOptions.Retry.MaxAttempts, "")
+	flag.DurationVar(&cli.CaptureOptions.Retry.InitialBackoff, "retry-backoff", captureOptions.Retry.InitialBackoff, "")
+	flag.DurationVar(&cli.CaptureOptions.Retry.InitialBackoff, "rb", captureOptions.Retry.InitialBackoff, "")
+	flag.DurationVar(&cli.CaptureOptions.Retry.MaxBackoff, "retry-max-backoff", captureOptions.Retry.MaxBackoff, "")
+	flag.DurationVar(&cli.CaptureOptions.Retry.MaxBackoff, "rmb", captureOptions.Retry.MaxBackoff, "")
+	flag.StringVar(&retryOn, "retry-on", "", "")
+	flag.StringVar(&retryOn, "ro", "", "")
 
 	flag.IntVar(&cli.CaptureOptions.CaptureHeight, "capture-height", captureOptions.CaptureHeight, "")
 	flag.IntVar(&cli.CaptureOptions.CaptureHeight, "ch", captureOptions.CaptureHeight, "")
@@ -235,10 +752,18 @@ func (cli *cli) parseFlags() {
 	flag.BoolVar(&cli.CaptureOptions.CaptureFull, "cf", captureOptions.CaptureFull, "")
 	flag.StringVar(&cli.CaptureOptions.Proxy, "proxy", captureOptions.Proxy, "")
 	flag.StringVar(&cli.CaptureOptions.Proxy, "p", captureOptions.Proxy, "")
+	flag.StringVar(&cli.CaptureOptions.WildcardMode, "wildcard", captureOptions.WildcardMode, "")
+	flag.StringVar(&cli.CaptureOptions.WildcardMode, "wc", captureOptions.WildcardMode, "")
+	flag.BoolVar(&cli.CaptureOptions.ScopeSubresources, "scope-subresources", captureOptions.ScopeSubresources, "")
+	flag.BoolVar(&cli.CaptureOptions.ScopeSubresources, "ss", captureOptions.ScopeSubresources, "")
+	flag.BoolVar(&cli.CaptureOptions.RecordDNS, "record-dns", captureOptions.RecordDNS, "")
+	flag.BoolVar(&cli.CaptureOptions.RecordDNS, "rdn", captureOptions.RecordDNS, "")
 
 	// OUTPUT
 	flag.BoolVar(&cli.NoImprint, "no-text", false, "")
 	flag.BoolVar(&cli.NoImprint, "nt", false, "")
+	flag.BoolVar(&cli.NoMetadata, "no-metadata", false, "")
+	flag.BoolVar(&cli.NoMetadata, "nm", false, "")
 	flag.BoolVar(&debug, "debug", false, "")
 	flag.BoolVar(&help, "help", false, "")
 	flag.BoolVar(&help, "h", false, "")
@@ -285,6 +810,23 @@ func (cli *cli) parseFlags() {
 		}
 	}
 
+	switch cli.InputFormat {
+	case screener.FormatPlain, screener.FormatNmap, screener.FormatMasscan, screener.FormatHTTPX,
+		screener.FormatBurp, screener.FormatZAP, screener.FormatSitemap:
+	default:
+		log.Errorf("Invalid input format: %s", cli.InputFormat)
+		os.Exit(1)
+	}
+
+	if ports != "" {
+		var err error
+		cli.Expand.Ports, err = screener.ParsePorts(ports)
+		if err != nil {
+			log.Errorf("Invalid ports: %v", err)
+			os.Exit(1)
+		}
+	}
+
 	if resolverFile != "" {
 		resolversFromFile, err := fileutil.ReadFile(resolverFile)
 		if err != nil {
@@ -308,16 +850,180 @@ func (cli *cli) parseFlags() {
 			}
 		}
 	}
+
+	switch cli.CaptureOptions.WildcardMode {
+	case "", screener.WildcardSkip, screener.WildcardTag:
+	default:
+		log.Errorf("Invalid wildcard mode: %s", cli.CaptureOptions.WildcardMode)
+		os.Exit(1)
+	}
+
+	for _, resolver := range cli.CaptureOptions.CustomResolvers {
+		if err := screener.ValidateResolver(resolver); err != nil {
+			log.Errorf("Invalid resolver: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if cli.CaptureOptions.Retry.MaxAttempts < 1 {
+		log.Errorf("Invalid retry attempts: %d", cli.CaptureOptions.Retry.MaxAttempts)
+		os.Exit(1)
+	}
+
+	if retryOn != "" {
+		var err error
+		cli.CaptureOptions.Retry.RetryOn, err = screener.ParseRetryOn(retryOn)
+		if err != nil {
+			log.Errorf("Invalid retry categories: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if cli.CaptureOptions.RateLimit < 0 || cli.CaptureOptions.HostRateLimit < 0 || cli.CaptureOptions.HostConcurrency < 0 {
+		log.Error("Rate limits and host concurrency must not be negative")
+		os.Exit(1)
+	}
+
+	if cli.CaptureOptions.BlockPrivate && cli.CaptureOptions.Proxy != "" {
+		log.Error("--block-private cannot be combined with --proxy")
+		os.Exit(1)
+	}
+
+	layout, err := screener.NewLayout(cli.FilenameTemplate, cli.HostDirs, cli.OnCollision)
+	if err != nil {
+		log.Errorf("Invalid output layout: %v", err)
+		os.Exit(1)
+	}
+	cli.layout = layout
+
+	if reportColumns != "" {
+		columns, err := screener.ParseReportColumns(reportColumns)
+		if err != nil {
+			log.Errorf("Invalid report columns: %v", err)
+			os.Exit(1)
+		}
+		cli.Report.Columns = columns
+	}
+
+	switch cli.Report.SortBy {
+	case "", screener.SortByHost, screener.SortByStatus:
+	default:
+		log.Errorf("Invalid report sort order %q, expected host or status", cli.Report.SortBy)
+		os.Exit(1)
+	}
+
+	fields, err := screener.ParseAnnotationFields(annotateFields)
+	if err != nil {
+		log.Errorf("Invalid annotation: %v", err)
+		os.Exit(1)
+	}
+	var fontFiles []string
+	if cli.AnnotateFont != "" {
+		fontFiles = strings.Split(cli.AnnotateFont, ",")
+	}
+	annotation, err := screener.NewAnnotation(fields, cli.AnnotatePosition, cli.AnnotateFontSize, fontFiles, cli.AnnotateColor, cli.AnnotateBackground)
+	if err != nil {
+		log.Errorf("Invalid annotation: %v", err)
+		os.Exit(1)
+	}
+	cli.annotation = annotation
+	if !cli.NoImprint && slices.Contains(annotation.Fields, screener.FieldIP) {
+		cli.CaptureOptions.RecordDNS = true
+	}
+
+	if cli.ThumbnailWidth < 0 {
+		log.Error("Thumbnail width must not be negative")
+		os.Exit(1)
+	}
+
+	if montage != "" {
+		columns, rows, err := screener.ParseGrid(montage)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		cli.Montage.Columns, cli.Montage.Rows = columns, rows
+	}
+
+	storage, err := screener.OpenStorage(cli.SaveScreenshotFolder)
+	if err != nil {
+		log.Errorf("Error opening output: %v", err)
+		os.Exit(1)
+	}
+	cli.storage = storage
+
+	if cli.ManifestFile != "" {
+		key, created, err := screener.LoadOrCreateSigningKey(cli.ManifestKey)
+		if err != nil {
+			log.Errorf("Error loading manifest key: %v", err)
+			os.Exit(1)
+		}
+		fingerprint := screener.KeyFingerprint(key.Public().(ed25519.PublicKey))
+		if created {
+			log.Infof("Created manifest key %s (%s), public key in %s.pub", cli.ManifestKey, fingerprint, cli.ManifestKey)
+		} else {
+			log.Debugf("Signing manifest with %s (%s)", cli.ManifestKey, fingerprint)
+		}
+		cli.signingKey = key
+		cli.manifest, err = screener.NewManifest("screener "+version, cli.SaveScreenshotFolder, filepath.Dir(cli.ManifestFile))
+		if err != nil {
+			log.Errorf("Error starting manifest: %v", err)
+			os.Exit(1)
+		}
+		cli.storage = cli.manifest.Wrap(storage)
+	}
+
+	if cli.Database != "" {
+		db, err := resultsdb.Open(cli.Database, strings.Join(os.Args, " "))
+		if err != nil {
+			log.Errorf("Error opening database: %v", err)
+			os.Exit(1)
+		}
+		db.StoreImages = cli.DatabaseImages
+		log.Debugf("Recording results in %s as run %d", cli.Database, db.RunID())
+		cli.db = db
+	}
+
+	if resume != "" {
+		state, err := screener.OpenStateFile(resume)
+		if err != nil {
+			log.Errorf("Error opening state file: %v", err)
+			os.Exit(1)
+		}
+		log.Infof("Resuming from %s, skipping %d completed targets and visited URLs", resume, state.Len())
+		cli.CaptureOptions.State = state
+	}
+
+	if scopeFile != "" {
+		scope, err := screener.LoadScope(scopeFile)
+		if err != nil {
+			log.Errorf("Error reading scope file: %v", err)
+			os.Exit(1)
+		}
+		cli.CaptureOptions.Scope = scope
+	}
 }
 
-var results []screener.Result
+var (
+	results      []screener.Result
+	reported     []screener.Result // every target processed, without images
+	resultsMutex sync.Mutex
+)
 
-func (cli *cli) worker(rawURL string) error {
+func (cli *cli) worker(ctx context.Context, target screener.Target) (outcome string) {
 	var err error
 	var result *screener.Result
 
-	rawURL = strings.TrimSuffix(rawURL, "/")
-	if !urlutil.HasScheme(rawURL) {
+	outcome = outcomeError
+	statusCode, recordURL := 0, target.URL
+	defer func() {
+		cli.progress.record(outcome, statusCode)
+		cli.recordCapture(recordURL, target.Metadata, result, err, outcome)
+	}()
+
+	rawURL := strings.TrimSuffix(target.URL, "/")
+	hasScheme := urlutil.HasScheme(rawURL)
+	if !hasScheme {
 		log.Debugf("No scheme specified for %q: defaulting to HTTPS", rawURL)
 		rawURL = "https://" + rawURL
 	}
@@ -326,7 +1032,7 @@ func (cli *cli) worker(rawURL string) error {
 	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
 		log.Errorf("Invalid URL %q: %v", rawURL, err)
-		return nil
+		return
 	}
 
 	urlutil.RemoveDefaultPort(parsedURL)
@@ -337,63 +1043,141 @@ func (cli *cli) worker(rawURL string) error {
 	}
 
 	cleanURL := parsedURL.String()
+	recordURL = cleanURL
 
-	result, err = cli.Screener.CaptureScreenshot(parsedURL)
-	if err != nil {
-		if shouldRetryWithHTTP(err) {
+	result, err = cli.captureURL(ctx, parsedURL)
+
+	// Expanded targets already queue http:// URLs where they should be tried
+	if err != nil && parsedURL.Scheme == "https" && !target.Expanded && shouldRetryWithHTTP(err) {
+		if hasScheme {
+			log.Infof("HTTPS failed %q: %s. Retrying with HTTP.", rawURL, unwrapError(err))
+		} else {
 			log.Debugf("HTTPS failed %q: %s. Retrying with HTTP.", rawURL, unwrapError(err))
-			parsedURL.Scheme = "http"
-			result, err = cli.Screener.CaptureScreenshot(parsedURL)
 		}
+		parsedURL.Scheme = "http"
+		result, err = cli.captureURL(ctx, parsedURL)
+	}
+
+	if result != nil {
+		result.Metadata = target.Metadata
+		statusCode = result.StatusCode
 	}
 
 	if err != nil {
-		handleCaptureError(rawURL, err)
-		return nil
+		outcome = handleCaptureError(rawURL, result, err)
+		return
 	}
 
 	if result == nil {
 		log.Warnf("Screenshot capture failed %q: no valid result", cleanURL)
-		return nil
+		outcome = outcomeIgnored
+		return
 	}
 
 	if result.StatusCode != 200 {
 		log.Warnf("Screenshot failed %q: server responded with HTTP %d", cleanURL, result.StatusCode)
-		return nil
+		outcome = outcomeStatus
+		return
 	}
 
+	resultsMutex.Lock()
 	if cli.AvoidDuplicates && result.IsSimilarToAny(results, cli.DuplicateThreshold) {
-		return nil
+		resultsMutex.Unlock()
+		outcome = outcomeDuplicate
+		return
 	}
-
 	results = append(results, *result)
+	resultsMutex.Unlock()
 
 	if !cli.NoImprint {
-		origin, err := urlutil.GetOrigin(result.TargetURL)
+		result.Image, err = result.Annotate(cli.annotation)
 		if err != nil {
-			log.Errorf("Error processing result URL %q: %v", result.TargetURL, err)
-			return nil
+			log.Errorf("Error adding text to image for %q: %v", result.TargetURL, err)
+			return
+		}
+	}
+
+	if cli.ThumbnailWidth > 0 {
+		result.Thumbnail, err = result.Image.Thumbnail(cli.ThumbnailWidth)
+		if err != nil {
+			log.Errorf("Error creating thumbnail for %q: %v", rawURL, err)
+			return
 		}
+	}
 
-		result.Image, err = result.Image.AddTextToImage(origin)
+	if !cli.NoMetadata {
+		metadata := result.ImageMetadata("screener " + version)
+		if result.Image, err = result.Image.EmbedMetadata(metadata); err == nil && len(result.Thumbnail) > 0 {
+			result.Thumbnail, err = result.Thumbnail.EmbedMetadata(metadata)
+		}
 		if err != nil {
-			log.Errorf("Error adding text to image for %q: %v", origin, err)
-			return nil
+			log.Errorf("Error embedding metadata for %q: %v", rawURL, err)
+			return
 		}
 	}
 
-	fn, err := result.SaveImageToFolder(cli.SaveScreenshotFolder)
+	_, err = result.Store(ctx, cli.storage, cli.layout)
+	if errors.Is(err, screener.ErrNameTaken) {
+		log.Warnf("Not saving %q: %v", rawURL, err)
+		outcome = outcomeIgnored
+		return
+	}
 	if err != nil {
 		log.Errorf("Error saving screenshot for %q: %v", rawURL, err)
-		return nil
+		return
 	}
 
-	log.Resultf("Screenshot saved %q", fn)
-	return nil
+	outcome = outcomeSaved
+	cli.progress.above(func() {
+		if result.Wildcard {
+			log.Resultf("Screenshot saved %q (wildcard)", result.ImageURI)
+		} else {
+			log.Resultf("Screenshot saved %q", result.ImageURI)
+		}
+	})
+	return
+}
+
+// recordCapture records the outcome of a target in the results database and
+// for reports. Targets that failed before producing a result are recorded
+// with their error and the metadata they were imported with.
+func (cli *cli) recordCapture(targetURL string, metadata map[string]string, result *screener.Result, err error, outcome string) {
+	reporting := cli.CSVReport != "" || cli.MarkdownReport != ""
+	if cli.db == nil && !reporting {
+		return
+	}
+
+	record := screener.Result{TargetURL: targetURL, Metadata: metadata}
+	if result != nil {
+		record = *result
+	}
+	if record.Error == nil {
+		record.Error = err
+	}
+
+	if err := cli.db.Record(record, outcome); err != nil {
+		log.Warnf("Error recording %s in database: %v", targetURL, err)
+	}
+
+	if reporting {
+		record.Image, record.Thumbnail = nil, nil
+		resultsMutex.Lock()
+		reported = append(reported, record)
+		resultsMutex.Unlock()
+	}
+}
+
+// captureURL captures u with the browser
+func (cli *cli) captureURL(ctx context.Context, u *url.URL) (*screener.Result, error) {
+	if cli.capture != nil {
+		return cli.capture(ctx, u)
+	}
+	return cli.Screener.CaptureScreenshotContext(ctx, u)
 }
 
 func shouldRetryWithHTTP(err error) bool {
-	if isDNSError(err) || isTimeoutError(err) {
+	if isDNSError(err) || isTimeoutError(err) || errors.Is(err, context.Canceled) || errors.Is(err, screener.ErrWildcard) ||
+		errors.Is(err, screener.ErrOutOfScope) || errors.Is(err, screener.ErrForbiddenDestination) {
 		return false
 	}
 	return true
@@ -447,14 +1231,36 @@ func unwrapError(err error) string {
 	return rootErr.Error()
 }
 
-func handleCaptureError(target string, err error) {
+// handleCaptureError logs a failed capture and returns its outcome
+func handleCaptureError(target string, result *screener.Result, err error) string {
 	switch {
+	case errors.Is(err, context.Canceled):
+		log.Debugf("Capture cancelled for %s", target)
+		return outcomeCancelled
+	case errors.Is(err, screener.ErrBrowserCrash):
+		log.Errorf("Browser failed while capturing %s: %v", target, err)
+		return outcomeCrash
+	case errors.Is(err, screener.ErrWildcard):
+		log.Warnf("Skipping wildcard DNS host %s", target)
+		return outcomeWildcard
+	case errors.Is(err, screener.ErrOutOfScope):
+		log.Warnf("Skipping %s: %v", target, err)
+		return outcomeScope
+	case errors.Is(err, screener.ErrForbiddenDestination):
+		log.Warnf("Skipping %s: %v", target, err)
+		return outcomeForbidden
+	case isDNSError(err) && result != nil && len(result.DNS.CNAMEs) > 0:
+		log.Warnf("DNS lookup failed %s (dangling CNAME %s)", target, strings.Join(result.DNS.CNAMEs, " -> "))
+		return outcomeDNS
 	case isDNSError(err):
 		log.Warnf("DNS lookup failed %s", target)
+		return outcomeDNS
 	case isTimeoutError(err):
 		log.Debugf("Timeout occurred while capturing screenshot for %s", target)
+		return outcomeTimeout
 	default:
 		log.Errorf("Error capturing screenshot for %s: %s", target, unwrapError(err))
+		return outcomeError
 	}
 }
 func (cli *cli) hasStdin() bool {
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Target outcomes recorded by the worker
+const (
+	outcomeSaved     = "saved"
+	outcomeDuplicate = "duplicate"
+	outcomeStatus    = "status"
+	outcomeIgnored   = "ignored"
+	outcomeResumed   = "resumed"
+	outcomeScope     = "scope"
+	outcomeForbidden = "forbidden"
+	outcomeWildcard  = "wildcard"
+	outcomeCancelled = "cancelled"
+	outcomeDNS       = "dns"
+	outcomeTimeout   = "timeout"
+	outcomeCrash     = "crash"
+	outcomeError     = "error"
+)
+
+// skippedOutcomes are outcomes where nothing went wrong but nothing was saved
+var skippedOutcomes = map[string]bool{
+	outcomeDuplicate: true,
+	outcomeIgnored:   true,
+	outcomeResumed:   true,
+	outcomeScope:     true,
+	outcomeForbidden: true,
+	outcomeWildcard:  true,
+	outcomeCancelled: true,
+}
+
+// transientOutcomes are failures that may not happen again, so the targets are
+// not recorded as completed in the state file
+var transientOutcomes = map[string]bool{
+	outcomeCancelled: true,
+	outcomeDNS:       true,
+	outcomeTimeout:   true,
+	outcomeCrash:     true,
+	outcomeError:     true,
+}
+
+// progress counts target outcomes and shows a progress line on stderr. On a
+// terminal the line is redrawn in place and log output is written above it;
+// otherwise a plain line is printed every interval so piped logs stay readable.
+type progress struct {
+	mutex       sync.Mutex
+	out         io.Writer
+	enabled     bool
+	tty         bool
+	interval    time.Duration
+	start       time.Time
+	queued      int
+	inputDone   bool
+	done        int
+	outcomes    map[string]int
+	statusCodes map[int]int
+	drawn       bool
+	stop        chan struct{}
+	stopped     chan struct{}
+}
+
+func newProgress(out *os.File, enabled bool) *progress {
+	p := &progress{
+		out:         out,
+		enabled:     enabled,
+		tty:         isTerminal(out),
+		interval:    10 * time.Second,
+		start:       time.Now(),
+		outcomes:    make(map[string]int),
+		statusCodes: make(map[int]int),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	if p.tty {
+		p.interval = 250 * time.Millisecond
+	}
+	return p
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// queue counts a target sent to the workers
+func (p *progress) queue() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.queued++
+}
+
+// finishInput marks the total number of targets as known
+func (p *progress) finishInput() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.inputDone = true
+}
+
+// record counts a processed target. A zero status code is not counted.
+func (p *progress) record(outcome string, statusCode int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.done++
+	p.outcomes[outcome]++
+	if statusCode != 0 {
+		p.statusCodes[statusCode]++
+	}
+}
+
+// Write clears the progress line before writing log output and redraws it after
+func (p *progress) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	redraw := p.drawn
+	p.clear()
+	n, err := p.out.Write(b)
+	if redraw {
+		p.draw()
+	}
+	return n, err
+}
+
+// above runs fn, which writes output of its own, with the progress line cleared
+func (p *progress) above(fn func()) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	redraw := p.drawn
+	p.clear()
+	fn()
+	if redraw {
+		p.draw()
+	}
+}
+
+func (p *progress) clear() {
+	if p.drawn {
+		fmt.Fprint(p.out, "\r\033[2K")
+		p.drawn = false
+	}
+}
+
+func (p *progress) draw() {
+	if p.tty {
+		fmt.Fprint(p.out, "\r\033[2K"+p.line())
+		p.drawn = true
+	} else {
+		fmt.Fprintln(p.out, p.line())
+	}
+}
+
+// run draws the progress line every interval until close is called
+func (p *progress) run() {
+	defer close(p.stopped)
+	if !p.enabled {
+		<-p.stop
+		return
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.mutex.Lock()
+			p.draw()
+			p.mutex.Unlock()
+		case <-p.stop:
+			p.mutex.Lock()
+			p.clear()
+			p.mutex.Unlock()
+			return
+		}
+	}
+}
+
+func (p *progress) close() {
+	close(p.stop)
+	<-p.stopped
+}
+
+// line formats done/total, rate, ETA and outcome counts
+func (p *progress) line() string {
+	elapsed := time.Since(p.start)
+	rate := float64(p.done) / elapsed.Seconds()
+
+	var sb strings.Builder
+	if p.inputDone {
+		fmt.Fprintf(&sb, "[screener] %d/%d", p.done, p.queued)
+		if p.queued > 0 {
+			fmt.Fprintf(&sb, " (%d%%)", p.done*100/p.queued)
+		}
+	} else {
+		fmt.Fprintf(&sb, "[screener] %d/%d+", p.done, p.queued)
+	}
+
+	fmt.Fprintf(&sb, " | %.1f/s", rate)
+	if p.inputDone && rate > 0 && p.done < p.queued {
+		eta := time.Duration(float64(p.queued-p.done) / rate * float64(time.Second))
+		fmt.Fprintf(&sb, " | ETA %v", eta.Round(time.Second))
+	}
+
+	failed, failedBy := p.group(false)
+	skipped, skippedBy := p.group(true)
+	fmt.Fprintf(&sb, " | ok %d | fail %d%s | skip %d%s", p.outcomes[outcomeSaved], failed, failedBy, skipped, skippedBy)
+
+	return sb.String()
+}
+
+// group sums failed or skipped outcomes and lists them by category
+func (p *progress) group(skipped bool) (int, string) {
+	var total int
+	var parts []string
+	for _, outcome := range sortedKeys(p.outcomes) {
+		if outcome == outcomeSaved || skippedOutcomes[outcome] != skipped {
+			continue
+		}
+		total += p.outcomes[outcome]
+		parts = append(parts, fmt.Sprintf("%s %d", outcome, p.outcomes[outcome]))
+	}
+
+	if len(parts) == 0 {
+		return total, ""
+	}
+	return total, " (" + strings.Join(parts, ", ") + ")"
+}
+
+// summary writes the end-of-run statistics table
+func (p *progress) summary(w io.Writer) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	elapsed := time.Since(p.start)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "\nSUMMARY")
+	fmt.Fprintf(tw, "  Targets\t%d\n", p.done)
+	fmt.Fprintf(tw, "  Saved\t%d\n", p.outcomes[outcomeSaved])
+	fmt.Fprintf(tw, "  Duplicates suppressed\t%d\n", p.outcomes[outcomeDuplicate])
+
+	if len(p.statusCodes) > 0 {
+		codes := make([]int, 0, len(p.statusCodes))
+		for code := range p.statusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+
+		var parts []string
+		for _, code := range codes {
+			parts = append(parts, fmt.Sprintf("%d: %d", code, p.statusCodes[code]))
+		}
+		fmt.Fprintf(tw, "  Status codes\t%s\n", strings.Join(parts, ", "))
+	}
+
+	if failed, failedBy := p.group(false); failed > 0 {
+		fmt.Fprintf(tw, "  Failed\t%d%s\n", failed, failedBy)
+	}
+	if skipped, skippedBy := p.group(true); skipped > 0 {
+		fmt.Fprintf(tw, "  Skipped\t%d%s\n", skipped, skippedBy)
+	}
+
+	fmt.Fprintf(tw, "  Total time\t%v\n", elapsed.Round(time.Millisecond))
+	tw.Flush()
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
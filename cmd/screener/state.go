@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runState tracks in-flight and completed targets so that an interrupted
+// run can report its progress and be resumed
+type runState struct {
+	mutex     sync.Mutex
+	inFlight  int
+	completed []string
+}
+
+func (s *runState) start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.inFlight++
+}
+
+// finish marks target as no longer in flight, and as completed unless its
+// capture was cancelled
+func (s *runState) finish(target string, completed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.inFlight--
+	if completed {
+		s.completed = append(s.completed, target)
+	}
+}
+
+func (s *runState) counts() (inFlight, completed int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.inFlight, len(s.completed)
+}
+
+// write saves the completed targets to path, one per line. The file is
+// replaced atomically so an earlier state file is never left half written.
+func (s *runState) write(path string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# screener state %s\n", time.Now().UTC().Format(time.RFC3339))
+	for _, target := range s.completed {
+		sb.WriteString(target)
+		sb.WriteByte('\n')
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(sb.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
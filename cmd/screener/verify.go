@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/root4loot/goutils/log"
+	"github.com/root4loot/screener/pkg/screener"
+)
+
+const verifyUsage = `USAGE:
+  screener verify [options] <manifest>
+
+Check the signature of a manifest written with --manifest, and that the files
+it lists are unchanged. Reports and the results database are looked for
+relative to the manifest.
+
+OPTIONS:
+  -k,   --key                    public key the manifest must be signed with             (Example: screener.key.pub)
+  -d,   --dir                    folder holding the files listed in the manifest         (Default: output of the run)
+`
+
+// verify checks the manifest in args and the files it lists, and returns the
+// exit code
+func verify(args []string) int {
+	var keyFile, dir string
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags.StringVar(&keyFile, "key", "", "")
+	flags.StringVar(&keyFile, "k", "", "")
+	flags.StringVar(&dir, "dir", "", "")
+	flags.StringVar(&dir, "d", "", "")
+	flags.Usage = func() {
+		fmt.Print(verifyUsage)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Print(verifyUsage)
+		return 1
+	}
+	path := flags.Arg(0)
+
+	var trusted ed25519.PublicKey
+	if keyFile != "" {
+		key, err := screener.LoadPublicKey(keyFile)
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+		trusted = key
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	manifest, signer, err := screener.VerifyManifest(data, trusted)
+	if err != nil {
+		log.Errorf("%s: %v", path, err)
+		return 1
+	}
+
+	log.Infof("Signature of %s is valid, signed by %s", path, screener.KeyFingerprint(signer))
+	if trusted == nil {
+		log.Warn("No --key given: the signature shows the manifest is intact, not who signed it")
+	}
+
+	if dir == "" {
+		if !screener.IsFolderOutput(manifest.Output) {
+			log.Errorf("Files were saved to %s: use --dir with a local copy of them", manifest.Output)
+			return 1
+		}
+		dir = manifest.OutputDir(filepath.Dir(path))
+	}
+
+	failed := 0
+	for _, artifact := range manifest.Artifacts {
+		base := dir
+		if artifact.Kind != "" {
+			base = filepath.Dir(path)
+		}
+
+		err := screener.VerifyArtifact(base, artifact)
+		switch {
+		case err == nil:
+			log.Debugf("OK %s", artifact.Name)
+			continue
+		case errors.Is(err, screener.ErrArtifactMissing):
+			log.Resultf("MISSING %s", artifact.Name)
+		case errors.Is(err, screener.ErrArtifactModified):
+			log.Resultf("MODIFIED %s", artifact.Name)
+		default:
+			log.Errorf("Error verifying %s: %v", artifact.Name, err)
+		}
+		failed++
+	}
+
+	if failed > 0 {
+		log.Errorf("%d of %d files in %s do not match the manifest", failed, len(manifest.Artifacts), dir)
+		return 1
+	}
+
+	log.Infof("All %d files in %s match the manifest of the run started %s", len(manifest.Artifacts), dir, manifest.StartedAt.Format("2006-01-02 15:04:05 MST"))
+	return 0
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/root4loot/screener/pkg/screener"
+)
+
+func TestWorkerHTTPFallback(t *testing.T) {
+	tests := []struct {
+		target screener.Target
+		want   []string
+	}{
+		{screener.Target{URL: "example.com"}, []string{"https://example.com", "http://example.com"}},
+		{screener.Target{URL: "https://example.com"}, []string{"https://example.com", "http://example.com"}},
+		{screener.Target{URL: "http://example.com"}, []string{"http://example.com"}},
+		// The http:// URL was queued by the expansion as well
+		{screener.Target{URL: "https://10.0.0.1:8080", Expanded: true}, []string{"https://10.0.0.1:8080"}},
+	}
+
+	for _, tt := range tests {
+		var mutex sync.Mutex
+		var captured []string
+
+		cli := NewCLIOptions()
+		cli.progress = newProgress(os.Stderr, false)
+		cli.capture = func(ctx context.Context, u *url.URL) (*screener.Result, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			captured = append(captured, u.String())
+			return nil, errors.New("net::ERR_CONNECTION_REFUSED")
+		}
+
+		if outcome := cli.worker(context.Background(), tt.target); outcome != outcomeError {
+			t.Errorf("%s: expected outcome %s, got %s", tt.target.URL, outcomeError, outcome)
+		}
+		if !reflect.DeepEqual(captured, tt.want) {
+			t.Errorf("%s: expected captures %q, got %q", tt.target.URL, tt.want, captured)
+		}
+	}
+}
+
+func TestWorkerRecordsMetadataOfFailedTargets(t *testing.T) {
+	defer func() { reported = nil }()
+
+	metadata := map[string]string{"source": screener.FormatNmap, "service": "http"}
+	for _, result := range []*screener.Result{nil, {StatusCode: 503}} {
+		reported = nil
+
+		cli := NewCLIOptions()
+		cli.CSVReport = "report.csv"
+		cli.progress = newProgress(os.Stderr, false)
+		cli.capture = func(ctx context.Context, u *url.URL) (*screener.Result, error) {
+			if result == nil {
+				return nil, errors.New("net::ERR_NAME_NOT_RESOLVED")
+			}
+			return result, nil
+		}
+
+		cli.worker(context.Background(), screener.Target{URL: "http://example.com", Metadata: metadata})
+		if len(reported) != 1 || !reflect.DeepEqual(reported[0].Metadata, metadata) {
+			t.Fatalf("expected the target's metadata to be recorded, got %+v", reported)
+		}
+	}
+}
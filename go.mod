@@ -15,10 +15,10 @@ require (
 	github.com/ysmood/leakless v0.8.0 // indirect
 	golang.org/x/crypto v0.38.0 // indirect
 	golang.org/x/mod v0.24.0 // indirect
-	golang.org/x/net v0.40.0 // indirect
 	golang.org/x/sync v0.14.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/term v0.32.0 // indirect
+	golang.org/x/text v0.25.0 // indirect
 	golang.org/x/tools v0.33.0 // indirect
 )
 
@@ -27,7 +27,9 @@ require (
 	github.com/glaslos/ssdeep v0.3.3
 	github.com/go-rod/rod v0.114.5
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/miekg/dns v1.1.68
 	github.com/root4loot/goutils v0.0.0-20250218135739-4fc09f3e142a
 	golang.org/x/image v0.14.0
+	golang.org/x/net v0.40.0
 )
@@ -0,0 +1,332 @@
+package screener
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/net/idna"
+)
+
+// Annotation fields
+const (
+	FieldOrigin     = "origin"
+	FieldURL        = "url"
+	FieldLandingURL = "landing_url"
+	FieldStatus     = "status"
+	FieldTitle      = "title"
+	FieldIP         = "ip"
+	FieldTimestamp  = "timestamp"
+	FieldResolver   = "resolver"
+)
+
+// Annotation positions
+const (
+	PositionTop    = "top"
+	PositionBottom = "bottom"
+)
+
+var annotationFields = map[string]bool{
+	FieldOrigin: true, FieldURL: true, FieldLandingURL: true, FieldStatus: true,
+	FieldTitle: true, FieldIP: true, FieldTimestamp: true, FieldResolver: true,
+}
+
+// Annotation describes the banner added to screenshots. Each field is
+// printed on its own line, wrapped to the width of the image.
+type Annotation struct {
+	Fields     []string
+	Position   string
+	FontSize   float64
+	Foreground color.Color
+	Background color.Color
+	fonts      *FontChain // nil for the embedded font and system fonts
+}
+
+// DefaultAnnotation prints the origin of the target in a 14pt banner at the bottom
+func DefaultAnnotation() *Annotation {
+	return &Annotation{
+		Fields:     []string{FieldOrigin},
+		Position:   PositionBottom,
+		FontSize:   14,
+		Foreground: color.Black,
+		Background: color.White,
+	}
+}
+
+// NewAnnotation validates the fields and position and loads the fonts in
+// fontFiles, as NewFontChain does. Characters are drawn with the first of these fonts that
+// has them, then the embedded Roboto font, then fonts installed on the system.
+// Colors are hex values such as #000, #1e1e1e or #1e1e1ecc.
+func NewAnnotation(fields []string, position string, fontSize float64, fontFiles []string, foreground, background string) (*Annotation, error) {
+	if len(fields) == 0 {
+		fields = []string{FieldOrigin}
+	}
+	for _, f := range fields {
+		if !annotationFields[f] {
+			return nil, fmt.Errorf("unknown annotation field %q", f)
+		}
+	}
+
+	switch position {
+	case "":
+		position = PositionBottom
+	case PositionTop, PositionBottom:
+	default:
+		return nil, fmt.Errorf("invalid annotation position %q, expected top or bottom", position)
+	}
+
+	if fontSize <= 0 {
+		return nil, fmt.Errorf("invalid font size %v", fontSize)
+	}
+
+	a := &Annotation{Fields: fields, Position: position, FontSize: fontSize}
+
+	var err error
+	if a.Foreground, err = ParseHexColor(foreground); err != nil {
+		return nil, err
+	}
+	if a.Background, err = ParseHexColor(background); err != nil {
+		return nil, err
+	}
+
+	if len(fontFiles) > 0 {
+		if a.fonts, err = NewFontChain(fontFiles...); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// ParseAnnotationFields parses a comma separated list of annotation fields
+func ParseAnnotationFields(s string) ([]string, error) {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if !annotationFields[f] {
+			return nil, fmt.Errorf("unknown annotation field %q", f)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// ParseHexColor parses #rgb, #rrggbb and #rrggbbaa colors
+func ParseHexColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return nil, fmt.Errorf("invalid color %q, expected a hex value such as #1e1e1e", s)
+	}
+
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// Annotate returns the image with the annotation banner added
+func (result Result) Annotate(a *Annotation) (Image, error) {
+	var lines []string
+	for _, field := range a.Fields {
+		if line := result.annotationLine(field); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return result.Image.drawBanner(lines, a)
+}
+
+func (result Result) annotationLine(field string) string {
+	switch field {
+	case FieldOrigin:
+		return displayIDN(printableOrigin(result.TargetURL))
+	case FieldURL:
+		return displayIDN(result.TargetURL)
+	case FieldLandingURL:
+		if result.LandingURL != "" {
+			return "Landing URL: " + displayIDN(result.LandingURL)
+		}
+	case FieldStatus:
+		if result.StatusCode != 0 {
+			return "Status: " + strconv.Itoa(result.StatusCode)
+		}
+	case FieldTitle:
+		if result.Title != "" {
+			return "Title: " + result.Title
+		}
+	case FieldIP:
+		if ips := result.DNS.IPs(); len(ips) > 0 {
+			return "IP: " + strings.Join(ips, ", ")
+		}
+	case FieldTimestamp:
+		if !result.CapturedAt.IsZero() {
+			return "Captured: " + result.CapturedAt.UTC().Format(time.RFC3339)
+		}
+	case FieldResolver:
+		if result.Resolver != "" {
+			return "Resolver: " + result.Resolver
+		}
+	}
+	return ""
+}
+
+// printableOrigin returns scheme://host of rawURL without a default port
+func printableOrigin(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	host := parsedURL.Host
+	if strings.Contains(host, ":") {
+		hostWithoutPort, port, _ := strings.Cut(host, ":")
+		if (parsedURL.Scheme == "http" && port == "80") || (parsedURL.Scheme == "https" && port == "443") {
+			host = hostWithoutPort
+		}
+	}
+
+	return parsedURL.Scheme + "://" + host
+}
+
+// displayIDN shows internationalized host names in rawURL in their Unicode
+// form followed by the punycode form, which is what was actually requested
+func displayIDN(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Host == "" {
+		return rawURL
+	}
+
+	host := parsedURL.Hostname()
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return rawURL
+	}
+	unicodeHost, err := idna.Display.ToUnicode(ascii)
+	if err != nil || unicodeHost == ascii {
+		return rawURL
+	}
+
+	// Replace the host in the original string to keep the rest as it was
+	i := strings.Index(rawURL, host)
+	if i < 0 {
+		return rawURL
+	}
+	return rawURL[:i] + unicodeHost + rawURL[i+len(host):] + " (" + ascii + ")"
+}
+
+// drawBanner adds lines of text in a banner above or below the image,
+// separated from it by a 1px line in the foreground color
+func (imgB Image) drawBanner(lines []string, a *Annotation) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(imgB))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	const borderSize = 1
+	padding := a.FontSize * 0.8
+	lineHeight := a.FontSize * 1.3
+
+	fonts := a.fonts
+	if fonts == nil {
+		if fonts, err = defaultFontChain(); err != nil {
+			return nil, err
+		}
+	}
+	face, err := fonts.Face(a.FontSize, lines...)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	w := img.Bounds().Dx()
+	measure := gg.NewContext(1, 1)
+	measure.SetFontFace(face)
+
+	var wrapped []string
+	for _, line := range lines {
+		wrapped = append(wrapped, wrapText(measure, line, float64(w)-2*padding)...)
+	}
+
+	bannerHeight := int(float64(len(wrapped))*lineHeight + 2*padding)
+	h := img.Bounds().Dy() + bannerHeight + borderSize
+	dc := gg.NewContext(w, h)
+
+	imageY, bannerY, lineY := 0, img.Bounds().Dy()+borderSize, img.Bounds().Dy()
+	if a.Position == PositionTop {
+		imageY, bannerY, lineY = bannerHeight+borderSize, 0, bannerHeight
+	}
+
+	dc.DrawImage(img, 0, imageY)
+
+	dc.SetColor(a.Background)
+	dc.DrawRectangle(0, float64(bannerY), float64(w), float64(bannerHeight))
+	dc.Fill()
+
+	dc.SetColor(a.Foreground)
+	dc.DrawRectangle(0, float64(lineY), float64(w), borderSize)
+	dc.Fill()
+
+	dc.SetFontFace(face)
+	for i, line := range wrapped {
+		y := float64(bannerY) + padding + (float64(i)+0.5)*lineHeight
+		dc.DrawStringAnchored(line, float64(w)/2, y, 0.5, 0.35)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dc.Image()); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// wrapText splits s into lines no wider than width, breaking at spaces where
+// possible and anywhere within long words such as URLs
+func wrapText(dc *gg.Context, s string, width float64) []string {
+	if w, _ := dc.MeasureString(s); w <= width || width <= 0 {
+		return []string{s}
+	}
+
+	var lines []string
+	var current []rune
+	lastSpace := -1
+
+	for _, r := range s {
+		current = append(current, r)
+		if r == ' ' {
+			lastSpace = len(current) - 1
+		}
+		if w, _ := dc.MeasureString(string(current)); w <= width || len(current) == 1 {
+			continue
+		}
+
+		// Break at the last space, or before the rune that didn't fit
+		cut := len(current) - 1
+		next := cut
+		if lastSpace > 0 {
+			cut, next = lastSpace, lastSpace+1
+		}
+		lines = append(lines, strings.TrimRight(string(current[:cut]), " "))
+		current = append([]rune{}, current[next:]...)
+		lastSpace = -1
+	}
+
+	if len(current) > 0 {
+		lines = append(lines, string(current))
+	}
+
+	return lines
+}
@@ -0,0 +1,127 @@
+package screener
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeTestPNG(t *testing.T, b []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestAnnotate(t *testing.T) {
+	result := Result{
+		TargetURL:  "https://example.com:443/" + strings.Repeat("long/path/", 20),
+		StatusCode: 200,
+		CapturedAt: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		Image:      testPNG(t, 400, 300, color.White),
+	}
+
+	single, err := NewAnnotation([]string{FieldOrigin}, PositionBottom, 14, nil, "#000", "#fff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := result.Annotate(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	singleHeight := decodeTestPNG(t, out).Bounds().Dy() - 300
+
+	a, err := NewAnnotation([]string{FieldURL, FieldStatus, FieldTitle, FieldTimestamp}, PositionTop, 14, nil, "#ffffff", "#202020")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = result.Annotate(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := decodeTestPNG(t, out)
+
+	// The long URL wraps, the empty title is left out, and the banner is on top
+	if img.Bounds().Dx() != 400 || img.Bounds().Dy()-300 < singleHeight*3 {
+		t.Fatalf("expected a taller banner for wrapped lines, got %v", img.Bounds())
+	}
+	if r, g, b, _ := img.At(1, 1).RGBA(); r>>8 != 0x20 || g>>8 != 0x20 || b>>8 != 0x20 {
+		t.Fatalf("expected banner background at the top, got %v", img.At(1, 1))
+	}
+	if r, _, _, _ := img.At(1, img.Bounds().Dy()-1).RGBA(); r>>8 != 0xff {
+		t.Fatalf("expected screenshot at the bottom, got %v", img.At(1, img.Bounds().Dy()-1))
+	}
+}
+
+func TestAnnotationLines(t *testing.T) {
+	result := Result{
+		TargetURL:  "https://example.com:443/login",
+		CapturedAt: time.Date(2026, 10, 18, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600)),
+		DNS:        DNSRecords{A: []string{"192.0.2.1"}, AAAA: []string{"2001:db8::1"}},
+		Resolver:   "tls://1.1.1.1:853",
+	}
+
+	tests := map[string]string{
+		FieldOrigin:    "https://example.com",
+		FieldURL:       "https://example.com:443/login",
+		FieldIP:        "IP: 192.0.2.1, 2001:db8::1",
+		FieldTimestamp: "Captured: 2026-10-18T10:00:00Z",
+		FieldResolver:  "Resolver: tls://1.1.1.1:853",
+		FieldStatus:    "",
+	}
+	for field, want := range tests {
+		if got := result.annotationLine(field); got != want {
+			t.Errorf("%s: expected %q, got %q", field, want, got)
+		}
+	}
+}
+
+func TestNewAnnotationInvalid(t *testing.T) {
+	if _, err := NewAnnotation([]string{"bogus"}, "", 14, nil, "#000", "#fff"); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	if _, err := NewAnnotation(nil, "left", 14, nil, "#000", "#fff"); err == nil {
+		t.Error("expected error for invalid position")
+	}
+	if _, err := NewAnnotation(nil, "", 14, nil, "black", "#fff"); err == nil {
+		t.Error("expected error for invalid color")
+	}
+
+	fontFile := filepath.Join(t.TempDir(), "font.ttf")
+	os.WriteFile(fontFile, []byte("not a font"), 0o644)
+	if _, err := NewAnnotation(nil, "", 14, []string{fontFile}, "#000", "#fff"); err == nil {
+		t.Error("expected error for invalid font")
+	}
+
+	fontFile = filepath.Join(t.TempDir(), "roboto.ttf")
+	os.WriteFile(fontFile, embeddedFont(), 0o644)
+	if _, err := NewAnnotation(nil, "", 20, []string{fontFile}, "#000", "#fff"); err != nil {
+		t.Errorf("expected custom font to load: %v", err)
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	tests := map[string]color.NRGBA{
+		"#000":     {A: 0xff},
+		"#1e2f3a":  {R: 0x1e, G: 0x2f, B: 0x3a, A: 0xff},
+		"1e2f3a80": {R: 0x1e, G: 0x2f, B: 0x3a, A: 0x80},
+		"#FFF":     {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+	for s, want := range tests {
+		got, err := ParseHexColor(s)
+		if err != nil || got != want {
+			t.Errorf("%s: expected %v, got %v (%v)", s, want, got, err)
+		}
+	}
+	if _, err := ParseHexColor("#12345"); err == nil {
+		t.Error("expected error for invalid length")
+	}
+}
@@ -0,0 +1,172 @@
+package screener
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveStorage writes artifacts into a single zip or tar archive. The
+// format is chosen by the extension of the path: .zip, .tar, .tar.gz or .tgz.
+// Metadata is stored as PAX records in tar archives and as the entry comment
+// in zip archives. The archive is only complete once Close returns.
+type ArchiveStorage struct {
+	mutex  sync.Mutex
+	path   string
+	file   *os.File
+	gzip   *gzip.Writer
+	tar    *tar.Writer
+	zip    *zip.Writer
+	closed bool
+}
+
+func isArchivePath(path string) bool {
+	return archiveFormat(path) != ""
+}
+
+func archiveFormat(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	default:
+		return ""
+	}
+}
+
+// NewArchiveStorage creates the archive at path, replacing any existing file
+func NewArchiveStorage(path string) (*ArchiveStorage, error) {
+	format := archiveFormat(path)
+	if format == "" {
+		return nil, fmt.Errorf("unknown archive format for %s", path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ArchiveStorage{path: path, file: f}
+	switch format {
+	case "zip":
+		s.zip = zip.NewWriter(f)
+	case "tar.gz":
+		s.gzip = gzip.NewWriter(f)
+		s.tar = tar.NewWriter(s.gzip)
+	case "tar":
+		s.tar = tar.NewWriter(f)
+	}
+
+	return s, nil
+}
+
+// Put adds data to the archive as name and returns path!/name
+func (s *ArchiveStorage) Put(ctx context.Context, name string, data []byte, metadata map[string]string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return "", fmt.Errorf("archive %s is closed", s.path)
+	}
+
+	now := time.Now()
+	if s.zip != nil {
+		header := &zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: now,
+			Comment:  formatMetadata(metadata),
+		}
+		header.SetMode(0o644)
+
+		w, err := s.zip.CreateHeader(header)
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write(data); err != nil {
+			return "", err
+		}
+	} else {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(data)),
+			ModTime: now,
+			Format:  tar.FormatPAX,
+		}
+		if len(metadata) > 0 {
+			header.PAXRecords = make(map[string]string, len(metadata))
+			for k, v := range metadata {
+				header.PAXRecords["SCREENER."+k] = v
+			}
+		}
+
+		if err := s.tar.WriteHeader(header); err != nil {
+			return "", err
+		}
+		if _, err := s.tar.Write(data); err != nil {
+			return "", err
+		}
+	}
+
+	return s.path + "!/" + name, nil
+}
+
+// Close finishes the archive and closes the file
+func (s *ArchiveStorage) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	var writers []io.Closer
+	if s.zip != nil {
+		writers = append(writers, s.zip)
+	}
+	if s.tar != nil {
+		writers = append(writers, s.tar)
+	}
+	if s.gzip != nil {
+		writers = append(writers, s.gzip)
+	}
+	writers = append(writers, s.file)
+
+	var firstErr error
+	for _, w := range writers {
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// formatMetadata formats metadata as sorted key=value lines
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s=%s\n", k, metadata[k])
+	}
+	return sb.String()
+}
@@ -0,0 +1,332 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/root4loot/goutils/log"
+	"golang.org/x/net/http/httpguts"
+)
+
+// ErrForbiddenDestination is wrapped by DestinationError
+var ErrForbiddenDestination = errors.New("forbidden destination")
+
+// DestinationError is returned when a target, redirect or subresource
+// resolves to a private, loopback, link-local or otherwise reserved address
+type DestinationError struct {
+	Host string
+	IP   string
+}
+
+func (e *DestinationError) Error() string {
+	if e.Host == e.IP {
+		return fmt.Sprintf("%s is a forbidden destination", e.IP)
+	}
+	return fmt.Sprintf("%s resolves to forbidden destination %s", e.Host, e.IP)
+}
+
+func (e *DestinationError) Unwrap() error {
+	return ErrForbiddenDestination
+}
+
+// reservedPrefixes are special-purpose ranges that are not globally
+// reachable, on top of what netip.Addr reports as private or local
+var reservedPrefixes = func() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, s := range []string{
+		"0.0.0.0/8",       // "this" network
+		"100.64.0.0/10",   // carrier-grade NAT
+		"192.0.0.0/24",    // IETF protocol assignments
+		"192.0.2.0/24",    // TEST-NET-1
+		"198.18.0.0/15",   // benchmarking
+		"198.51.100.0/24", // TEST-NET-2
+		"203.0.113.0/24",  // TEST-NET-3
+		"240.0.0.0/4",     // reserved, including broadcast
+		"64:ff9b:1::/48",  // local-use NAT64
+		"100::/64",        // discard-only
+		"2001:db8::/32",   // documentation
+	} {
+		prefixes = append(prefixes, netip.MustParsePrefix(s))
+	}
+	return prefixes
+}()
+
+// nat64Prefix embeds IPv4 addresses, which are checked in turn
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// IsForbiddenIP reports whether addr is private, loopback, link-local,
+// multicast, unspecified or in another reserved range
+func IsForbiddenIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if nat64Prefix.Contains(addr) {
+		b := addr.As16()
+		return IsForbiddenIP(netip.AddrFrom4([4]byte(b[12:])))
+	}
+
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkDestination returns a DestinationError if any of the addresses of host is forbidden
+func checkDestination(host string, ips []string) error {
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil || IsForbiddenIP(addr) {
+			return &DestinationError{Host: host, IP: ip}
+		}
+	}
+	return nil
+}
+
+// guardProxy is an HTTP proxy that the browser sends every request through
+// when private destinations are blocked or custom resolvers are used. Hosts
+// are resolved, and checked when blocking, at dial time, so DNS rebinding
+// between the initial check and a later request or redirect is caught as well.
+type guardProxy struct {
+	screener     *Screener
+	contextTag   string
+	blockPrivate bool
+	listener     net.Listener
+	server       *http.Server
+	transport    *http.Transport
+	mutex        sync.Mutex
+	blocked      map[string]error
+}
+
+// startGuardProxy starts a guard proxy on a random loopback port. Forbidden
+// destinations are only refused when blockPrivate is set.
+func (s *Screener) startGuardProxy(contextTag string, blockPrivate bool) (*guardProxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &guardProxy{
+		screener:     s,
+		contextTag:   contextTag,
+		blockPrivate: blockPrivate,
+		listener:     listener,
+		blocked:      make(map[string]error),
+	}
+	p.transport = &http.Transport{
+		DialContext:           p.dialContext,
+		Proxy:                 nil,
+		ForceAttemptHTTP2:     false,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Duration(s.CaptureOptions.Timeout) * time.Second,
+	}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: 10 * time.Second}
+
+	go p.server.Serve(listener)
+
+	return p, nil
+}
+
+// URL returns the address to pass to the browser as its proxy server
+func (p *guardProxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+// Close stops the proxy and closes idle upstream connections
+func (p *guardProxy) Close() {
+	p.server.Close()
+	p.transport.CloseIdleConnections()
+}
+
+// blockedErr returns the error for a blocked host:port, if it was blocked
+func (p *guardProxy) blockedErr(hostPort string) error {
+	if p == nil {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.blocked[strings.ToLower(hostPort)]
+}
+
+// Blocked returns the number of distinct destinations that were blocked
+func (p *guardProxy) Blocked() int {
+	if p == nil {
+		return 0
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.blocked)
+}
+
+func (p *guardProxy) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	_, records, err := p.screener.resolveHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := records.IPs()
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+
+	if p.blockPrivate {
+		if err := checkDestination(host, ips); err != nil {
+			log.Debugf("%s Blocking request to %s: %v", p.contextTag, address, err)
+			p.mutex.Lock()
+			p.blocked[strings.ToLower(address)] = err
+			p.mutex.Unlock()
+			return nil, err
+		}
+	}
+
+	// Dial the resolved addresses rather than letting the dialer resolve again
+	var dialer net.Dialer
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
+
+func (p *guardProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+		return
+	}
+
+	if r.URL.Host == "" {
+		http.Error(w, "proxy requests must use an absolute URL", http.StatusBadRequest)
+		return
+	}
+
+	if r.Header.Get("Upgrade") != "" && httpguts.HeaderValuesContainsToken(r.Header["Connection"], "Upgrade") {
+		p.serveUpgrade(w, r)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range []string{"Proxy-Connection", "Proxy-Authorization", "Connection", "Keep-Alive", "Te", "Trailer", "Upgrade"} {
+		out.Header.Del(h)
+	}
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		writeProxyError(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	for key, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func (p *guardProxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.dialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		writeProxyError(w, err)
+		return
+	}
+
+	p.tunnel(w, upstream, []byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+}
+
+// serveUpgrade forwards a request to switch protocols, such as a ws://
+// WebSocket handshake, and tunnels the connection once the destination has
+// been checked, so the upstream answers the handshake itself
+func (p *guardProxy) serveUpgrade(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Host
+	if r.URL.Port() == "" {
+		address = net.JoinHostPort(r.URL.Hostname(), "80")
+	}
+
+	upstream, err := p.dialContext(r.Context(), "tcp", address)
+	if err != nil {
+		writeProxyError(w, err)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.Header.Del("Proxy-Connection")
+	out.Header.Del("Proxy-Authorization")
+	if err := out.Write(upstream); err != nil {
+		upstream.Close()
+		writeProxyError(w, err)
+		return
+	}
+
+	p.tunnel(w, upstream, nil)
+}
+
+// tunnel hijacks the client connection, sends it greeting, if any, and copies
+// data both ways between it and upstream until either side closes
+func (p *guardProxy) tunnel(w http.ResponseWriter, upstream net.Conn, greeting []byte) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client, buf, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+
+	if len(greeting) > 0 {
+		if _, err := client.Write(greeting); err != nil {
+			client.Close()
+			upstream.Close()
+			return
+		}
+	}
+
+	go func() {
+		// Flush anything the client sent before the tunnel was established
+		if n := buf.Reader.Buffered(); n > 0 {
+			data, _ := buf.Reader.Peek(n)
+			upstream.Write(data)
+		}
+		io.Copy(upstream, client)
+		upstream.Close()
+	}()
+	io.Copy(client, upstream)
+	client.Close()
+}
+
+func writeProxyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrForbiddenDestination) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
@@ -0,0 +1,198 @@
+package screener
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestIsForbiddenIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"255.255.255.255", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"8.8.8.8", false},
+		{"93.184.216.34", false},
+		{"2606:4700:4700::1111", false},
+		{"64:ff9b::808:808", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsForbiddenIP(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("IsForbiddenIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestGuardProxyBlocksPrivateDestinations(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer target.Close()
+
+	s := NewScreener()
+	proxy, err := s.startGuardProxy("[test]", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	// Plain HTTP is forwarded by the proxy
+	resp, err := client.Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for loopback destination, got %d", resp.StatusCode)
+	}
+
+	hostPort := strings.TrimPrefix(target.URL, "http://")
+	if err := proxy.blockedErr(hostPort); !errors.Is(err, ErrForbiddenDestination) {
+		t.Fatalf("expected %s to be recorded as blocked, got %v", hostPort, err)
+	}
+
+	// HTTPS is tunnelled with CONNECT
+	tlsTarget := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsTarget.Close()
+
+	client.Transport.(*http.Transport).TLSClientConfig = tlsTarget.Client().Transport.(*http.Transport).TLSClientConfig
+	if _, err := client.Get(tlsTarget.URL); err == nil {
+		t.Fatal("expected CONNECT to loopback destination to fail")
+	}
+	if proxy.Blocked() != 2 {
+		t.Fatalf("expected 2 blocked destinations, got %d", proxy.Blocked())
+	}
+}
+
+func TestGuardProxyUsesCustomResolvers(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("only custom"))
+	}))
+	defer target.Close()
+
+	s := NewScreener()
+	s.CaptureOptions.CustomResolvers = []string{startLocalResolver(t, "only-custom.test")}
+	proxy, err := s.startGuardProxy("[test]", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	// A name the system resolver doesn't know is reached, loopback included
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(target.URL, "http://"))
+	resp, err := client.Get("http://only-custom.test:" + port + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "only custom" {
+		t.Fatalf("expected the page behind the custom name, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestGuardProxyTunnelsUpgrades(t *testing.T) {
+	// An upstream that switches to echoing whatever it is sent
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "expected upgrade", http.StatusBadRequest)
+			return
+		}
+		conn, buf, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+		io.Copy(conn, buf)
+	}))
+	defer target.Close()
+
+	upgrade := func(blockPrivate bool) (*http.Response, net.Conn) {
+		s := NewScreener()
+		proxy, err := s.startGuardProxy("[test]", blockPrivate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(proxy.Close)
+
+		conn, err := net.Dial("tcp", strings.TrimPrefix(proxy.URL(), "http://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		req, _ := http.NewRequest(http.MethodGet, target.URL+"/socket", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "echo")
+		if err := req.WriteProxy(conn); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, conn
+	}
+
+	// Allowed destinations get a tunnel once the upstream switches protocols
+	resp, conn := upgrade(false)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	conn.Write([]byte("ping"))
+	echo := make([]byte, 4)
+	if _, err := io.ReadFull(conn, echo); err != nil || string(echo) != "ping" {
+		t.Fatalf("expected the tunnel to echo, got %q: %v", echo, err)
+	}
+
+	// Forbidden ones are still refused
+	if resp, _ := upgrade(true); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for loopback destination, got %d", resp.StatusCode)
+	}
+}
+
+// startLocalResolver starts a DNS server that resolves name to 127.0.0.1 and
+// nothing else, and returns it as a custom resolver
+func startLocalResolver(t *testing.T, name string) string {
+	return "udp://" + startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := &dns.Msg{}
+		m.SetReply(r)
+		switch {
+		case !strings.EqualFold(r.Question[0].Name, dns.Fqdn(name)):
+			m.Rcode = dns.RcodeNameError
+		case r.Question[0].Qtype == dns.TypeA:
+			rr, _ := dns.NewRR(dns.Fqdn(name) + " 60 IN A 127.0.0.1")
+			m.Answer = append(m.Answer, rr)
+		}
+		w.WriteMsg(m)
+	})
+}
@@ -0,0 +1,238 @@
+package screener
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFilenameTemplate names files like https_example.com_8080_blog_post_3f2a1b9c
+const DefaultFilenameTemplate = "{scheme}_{host}_{port}_{path}_{query_hash}"
+
+// Collision modes for names already used during a run or already stored
+const (
+	CollisionSuffix    = "suffix"
+	CollisionOverwrite = "overwrite"
+	CollisionSkip      = "skip"
+)
+
+// ErrNameTaken is returned when a file name was already used and collisions are skipped
+var ErrNameTaken = errors.New("file name already used")
+
+// maxNameLength leaves room for a collision suffix and extension within the
+// 255 byte limit most filesystems put on a single name
+const maxNameLength = 200
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{[a-z_]+\}`)
+	unsafeNameChars    = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	repeatedSeparators = regexp.MustCompile(`([_-])[_-]+`)
+)
+
+var placeholders = map[string]bool{
+	"{scheme}": true, "{host}": true, "{port}": true, "{path}": true,
+	"{query_hash}": true, "{status}": true, "{date}": true, "{viewport}": true,
+}
+
+// Layout turns results into relative file paths. Templates may use
+// {scheme}, {host}, {port}, {path}, {query_hash}, {status}, {date} and
+// {viewport}, and / to create subdirectories. A Layout remembers the names it
+// handed out so that different URLs mapping to the same name don't overwrite
+// each other, and is safe for concurrent use.
+type Layout struct {
+	Template    string
+	PerHostDirs bool
+	OnCollision string
+	mutex       sync.Mutex
+	used        map[string]bool
+}
+
+// NewLayout validates template and the collision mode and returns a Layout
+func NewLayout(template string, perHostDirs bool, onCollision string) (*Layout, error) {
+	if template == "" {
+		template = DefaultFilenameTemplate
+	}
+
+	for _, p := range placeholderPattern.FindAllString(template, -1) {
+		if !placeholders[p] {
+			return nil, fmt.Errorf("unknown placeholder %s in filename template", p)
+		}
+	}
+
+	switch onCollision {
+	case "":
+		onCollision = CollisionSuffix
+	case CollisionSuffix, CollisionOverwrite, CollisionSkip:
+	default:
+		return nil, fmt.Errorf("invalid collision mode %q", onCollision)
+	}
+
+	return &Layout{
+		Template:    template,
+		PerHostDirs: perHostDirs,
+		OnCollision: onCollision,
+		used:        make(map[string]bool),
+	}, nil
+}
+
+// Name returns the relative path for result with the given extension,
+// resolving collisions with names handed out earlier
+func (l *Layout) Name(result Result, ext string) (string, error) {
+	return l.name(result, ext, nil)
+}
+
+// NameIn is like Name, but when storage implements NameChecker names already
+// stored there, such as files from an earlier run into the same folder, are
+// treated as taken too
+func (l *Layout) NameIn(ctx context.Context, storage Storage, result Result, ext string) (string, error) {
+	checker, ok := storage.(NameChecker)
+	if !ok || l.OnCollision == CollisionOverwrite {
+		return l.Name(result, ext)
+	}
+
+	return l.name(result, ext, func(name string) (bool, error) {
+		return checker.Exists(ctx, filepath.ToSlash(name))
+	})
+}
+
+func (l *Layout) name(result Result, ext string, stored func(string) (bool, error)) (string, error) {
+	template := l.Template
+	if l.PerHostDirs {
+		template = "{host}/" + template
+	}
+	name := result.renderTemplate(template)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	candidate := name + ext
+	for n := 2; ; n++ {
+		taken, err := l.taken(candidate, stored)
+		if err != nil {
+			return "", fmt.Errorf("error checking %s: %w", candidate, err)
+		}
+		if !taken {
+			break
+		}
+
+		switch l.OnCollision {
+		case CollisionOverwrite:
+			return candidate, nil
+		case CollisionSkip:
+			return "", fmt.Errorf("%w: %s", ErrNameTaken, candidate)
+		}
+		candidate = name + "-" + strconv.Itoa(n) + ext
+	}
+
+	l.used[strings.ToLower(candidate)] = true
+	return candidate, nil
+}
+
+// taken reports whether name was handed out before or is already stored
+func (l *Layout) taken(name string, stored func(string) (bool, error)) (bool, error) {
+	if l.used[strings.ToLower(name)] {
+		return true, nil
+	}
+	if stored == nil {
+		return false, nil
+	}
+	return stored(name)
+}
+
+// renderTemplate fills in the placeholders of template for result and makes
+// every path segment safe to use as a file name
+func (result Result) renderTemplate(template string) string {
+	values := result.templateValues()
+	rendered := placeholderPattern.ReplaceAllStringFunc(template, func(p string) string {
+		return strings.ReplaceAll(values[p], "/", "_")
+	})
+
+	segments := strings.Split(rendered, "/")
+	for i, segment := range segments {
+		segments[i] = safeSegment(segment)
+	}
+
+	return filepath.Join(segments...)
+}
+
+func (result Result) templateValues() map[string]string {
+	target, _ := url.Parse(result.TargetURL)
+	if target == nil {
+		target = &url.URL{}
+	}
+
+	scheme := target.Scheme
+	if landing, err := url.Parse(result.LandingURL); err == nil && landing.Scheme != "" {
+		scheme = landing.Scheme
+	}
+
+	port := target.Port()
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	var queryHash string
+	if target.RawQuery != "" {
+		queryHash = shortHash(target.RawQuery)
+	}
+
+	var status string
+	if result.StatusCode != 0 {
+		status = strconv.Itoa(result.StatusCode)
+	}
+
+	captured := result.CapturedAt
+	if captured.IsZero() {
+		captured = time.Now()
+	}
+
+	return map[string]string{
+		"{scheme}":     scheme,
+		"{host}":       strings.ToLower(target.Hostname()),
+		"{port}":       port,
+		"{path}":       strings.Trim(target.Path, "/"),
+		"{query_hash}": queryHash,
+		"{status}":     status,
+		"{date}":       captured.Format("20060102"),
+		"{viewport}":   result.Viewport,
+	}
+}
+
+// safeSegment replaces unsafe characters, collapses separators left behind by
+// empty placeholders, strips surrounding dots so names can't be hidden or refer to
+// parent directories, and truncates long names keeping a hash of the original
+func safeSegment(segment string) string {
+	s := unsafeNameChars.ReplaceAllString(segment, "_")
+	s = repeatedSeparators.ReplaceAllString(s, "$1")
+	s = strings.Trim(s, "._-")
+
+	if s == "" {
+		return "_"
+	}
+
+	if len(s) > maxNameLength {
+		s = s[:maxNameLength-9] + "-" + shortHash(segment)
+	}
+
+	return s
+}
+
+func shortHash(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:4])
+}
+
+// SaveImage writes the image to folder under the name given by layout and
+// returns the path written. Nothing is written for results without an image.
+func (result Result) SaveImage(folder string, layout *Layout) (string, error) {
+	return result.Store(context.Background(), &FileStorage{Dir: folder}, layout)
+}
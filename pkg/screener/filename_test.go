@@ -0,0 +1,144 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayoutName(t *testing.T) {
+	captured := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		template string
+		hostDirs bool
+		result   Result
+		want     string
+	}{
+		{"", false, Result{TargetURL: "https://Example.com/"}, "https_example.com.png"},
+		{"", false, Result{TargetURL: "http://example.com:8080/blog/post", LandingURL: "https://example.com:8080/blog/post"}, "https_example.com_8080_blog_post.png"},
+		{"", false, Result{TargetURL: "https://example.com/item?id=1"}, "https_example.com_item_" + shortHash("id=1") + ".png"},
+		{"{host}/{status}_{path}", false, Result{TargetURL: "https://example.com/a/b", StatusCode: 200}, filepath.Join("example.com", "200_a_b.png")},
+		{"{date}_{viewport}_{host}", true, Result{TargetURL: "https://example.com/", CapturedAt: captured, Viewport: "1366x768"}, filepath.Join("example.com", "20240501_1366x768_example.com.png")},
+		{"{path}", false, Result{TargetURL: "https://example.com/../etc/passwd"}, "etc_passwd.png"},
+	}
+
+	for _, tt := range tests {
+		layout, err := NewLayout(tt.template, tt.hostDirs, CollisionSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := layout.Name(tt.result, ".png"); got != tt.want {
+			t.Errorf("Name(%s, %q) = %s, want %s", tt.result.TargetURL, tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestLayoutTruncatesLongNames(t *testing.T) {
+	layout, _ := NewLayout("{path}", false, CollisionSuffix)
+
+	long := "https://example.com/" + strings.Repeat("a", 300)
+	name, _ := layout.Name(Result{TargetURL: long}, ".png")
+	if len(name) > 255 {
+		t.Fatalf("expected name within 255 bytes, got %d", len(name))
+	}
+
+	other, _ := layout.Name(Result{TargetURL: long + "b"}, ".png")
+	if name == other {
+		t.Fatalf("expected truncated names of different URLs to differ, got %s", name)
+	}
+}
+
+func TestLayoutCollisions(t *testing.T) {
+	result := Result{TargetURL: "https://example.com/"}
+
+	suffix, _ := NewLayout("{host}", false, CollisionSuffix)
+	first, _ := suffix.Name(result, ".png")
+	second, _ := suffix.Name(result, ".png")
+	if first != "example.com.png" || second != "example.com-2.png" {
+		t.Fatalf("expected suffixed name, got %s and %s", first, second)
+	}
+
+	skip, _ := NewLayout("{host}", false, CollisionSkip)
+	skip.Name(result, ".png")
+	if _, err := skip.Name(result, ".png"); !errors.Is(err, ErrNameTaken) {
+		t.Fatalf("expected ErrNameTaken, got %v", err)
+	}
+
+	overwrite, _ := NewLayout("{host}", false, CollisionOverwrite)
+	overwrite.Name(result, ".png")
+	if name, _ := overwrite.Name(result, ".png"); name != "example.com.png" {
+		t.Fatalf("expected same name when overwriting, got %s", name)
+	}
+
+	if _, err := NewLayout("{nope}", false, CollisionSuffix); err == nil {
+		t.Fatal("expected error for unknown placeholder")
+	}
+}
+
+func TestSaveImageKeepsFolderCase(t *testing.T) {
+	folder := filepath.Join(t.TempDir(), "Screens")
+	layout, _ := NewLayout("", true, CollisionSuffix)
+
+	fn, err := Result{TargetURL: "https://example.com/", Image: Image("png")}.SaveImage(folder, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(folder, "example.com", "https_example.com.png"); fn != want {
+		t.Fatalf("got %s, want %s", fn, want)
+	}
+	if _, err := os.Stat(fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLayoutNameInStorage(t *testing.T) {
+	dir := t.TempDir()
+	storage := &FileStorage{Dir: dir}
+	result := Result{TargetURL: "https://example.com/", Image: []byte("png")}
+
+	// A file left by an earlier run is not overwritten
+	os.WriteFile(filepath.Join(dir, "example.com.png"), []byte("earlier"), 0o644)
+
+	suffix, _ := NewLayout("{host}", false, CollisionSuffix)
+	if name, _ := suffix.NameIn(context.Background(), storage, result, ".png"); name != "example.com-2.png" {
+		t.Fatalf("expected name not on disk yet, got %s", name)
+	}
+
+	skip, _ := NewLayout("{host}", false, CollisionSkip)
+	if _, err := result.Store(context.Background(), storage, skip); !errors.Is(err, ErrNameTaken) {
+		t.Fatalf("expected ErrNameTaken, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "example.com.png")); string(data) != "earlier" {
+		t.Fatalf("expected earlier file to be kept, got %q", data)
+	}
+
+	overwrite, _ := NewLayout("{host}", false, CollisionOverwrite)
+	if name, _ := overwrite.NameIn(context.Background(), storage, result, ".png"); name != "example.com.png" {
+		t.Fatalf("expected same name when overwriting, got %s", name)
+	}
+}
+
+func TestSaveImageToFolderKeepsNames(t *testing.T) {
+	dir := t.TempDir()
+	result := Result{
+		TargetURL:  "http://Example.com:8080/blog/post/",
+		LandingURL: "https://example.com:8080/blog/post/",
+		Image:      []byte("png"),
+	}
+
+	filename, err := result.SaveImageToFolder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "https_example.com-8080_blog_post.png"); filename != want {
+		t.Fatalf("expected %s, got %s", want, filename)
+	}
+	if _, err := os.Stat(filename); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,291 @@
+package screener
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	// fontCache holds the fonts parsed from each file by path, nil for files
+	// that failed to parse
+	fontCache sync.Map
+
+	systemFontsOnce  sync.Once
+	systemFontPaths  []string
+	defaultChainOnce sync.Once
+	defaultChain     *FontChain
+	defaultChainErr  error
+)
+
+// fontExtensions are the font files looked for in the system font folders.
+// TrueType and CFF outlines are supported, in single fonts and collections.
+// Color bitmap fonts, such as Noto Color Emoji, have no outlines to draw and
+// are skipped.
+var fontExtensions = map[string]bool{".ttf": true, ".otf": true, ".ttc": true, ".otc": true}
+
+// FontChain is a list of fonts tried in order for each character. Characters
+// none of them cover are looked up in the fonts installed on the system, so
+// that scripts such as CJK render when a suitable font exists. A FontChain is
+// safe for concurrent use.
+type FontChain struct {
+	mutex   sync.Mutex
+	fonts   []*sfnt.Font
+	system  []*sfnt.Font  // system fonts parsed so far
+	next    int           // index of the next system font file to parse
+	missing map[rune]bool // characters no font covers
+	idle    map[faceKey][]font.Face
+	buf     sfnt.Buffer
+}
+
+// faceKey identifies the faces of a font at a size
+type faceKey struct {
+	font *sfnt.Font
+	size float64
+}
+
+// NewFontChain loads the fonts in files, followed by the embedded Roboto
+// font. Files may be TrueType or OpenType fonts or font collections, of which
+// every font is used.
+func NewFontChain(files ...string) (*FontChain, error) {
+	c := &FontChain{missing: make(map[rune]bool), idle: make(map[faceKey][]font.Face)}
+	for _, path := range files {
+		fonts, err := parseFontFile(path)
+		if err != nil {
+			return nil, err
+		}
+		c.fonts = append(c.fonts, fonts...)
+	}
+
+	f, err := sfnt.Parse(embeddedFont())
+	if err != nil {
+		return nil, fmt.Errorf("error parsing embedded font: %w", err)
+	}
+	c.fonts = append(c.fonts, f)
+
+	return c, nil
+}
+
+// defaultFontChain returns a shared chain of the embedded font and system fonts
+func defaultFontChain() (*FontChain, error) {
+	defaultChainOnce.Do(func() {
+		defaultChain, defaultChainErr = NewFontChain()
+	})
+	return defaultChain, defaultChainErr
+}
+
+// Face returns a face of the given size that covers as much of texts as the
+// available fonts allow. Closing the face hands its font faces back to the
+// chain for later calls to reuse, so they are only built once per font and
+// size for each face in use at the same time.
+func (c *FontChain) Face(size float64, texts ...string) (font.Face, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, text := range texts {
+		for _, r := range text {
+			c.cover(r)
+		}
+	}
+
+	face := &fallbackFace{chain: c, size: size, fonts: c.fonts, picked: make(map[rune]font.Face)}
+	for _, f := range c.fonts {
+		key := faceKey{f, size}
+		if idle := c.idle[key]; len(idle) > 0 {
+			face.faces = append(face.faces, idle[len(idle)-1])
+			c.idle[key] = idle[:len(idle)-1]
+			continue
+		}
+
+		ff, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72})
+		if err != nil {
+			c.release(face)
+			return nil, err
+		}
+		face.faces = append(face.faces, ff)
+	}
+	return face, nil
+}
+
+// release puts the font faces of face back for reuse. c.mutex must be held.
+func (c *FontChain) release(face *fallbackFace) {
+	for i, ff := range face.faces {
+		key := faceKey{face.fonts[i], face.size}
+		c.idle[key] = append(c.idle[key], ff)
+	}
+	face.faces = nil
+}
+
+// cover adds a system font covering r to the chain if none of its fonts do
+func (c *FontChain) cover(r rune) {
+	if !unicode.IsGraphic(r) || unicode.IsSpace(r) || c.missing[r] {
+		return
+	}
+	for _, f := range c.fonts {
+		if hasGlyph(f, &c.buf, r) {
+			return
+		}
+	}
+
+	paths := systemFonts()
+	for i := 0; ; i++ {
+		for i == len(c.system) {
+			if c.next == len(paths) {
+				c.missing[r] = true
+				return
+			}
+			fonts, _ := parseFontFile(paths[c.next])
+			c.system = append(c.system, fonts...)
+			c.next++
+		}
+
+		if f := c.system[i]; hasGlyph(f, &c.buf, r) {
+			c.fonts = append(c.fonts, f)
+			return
+		}
+	}
+}
+
+// hasGlyph reports whether f has an outline for r. Fonts that map r to a
+// color bitmap only don't count, as they can't be drawn.
+func hasGlyph(f *sfnt.Font, buf *sfnt.Buffer, r rune) bool {
+	x, err := f.GlyphIndex(buf, r)
+	if err != nil || x == 0 {
+		return false
+	}
+	_, err = f.LoadGlyph(buf, x, fixed.I(16), nil)
+	return err == nil
+}
+
+// parseFontFile parses the font, or each font of the collection, in path
+func parseFontFile(path string) ([]*sfnt.Font, error) {
+	if fonts, ok := fontCache.Load(path); ok {
+		if fonts.([]*sfnt.Font) == nil {
+			return nil, fmt.Errorf("%s is not a supported font", path)
+		}
+		return fonts.([]*sfnt.Font), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := sfnt.ParseCollection(data)
+	if err != nil {
+		fontCache.Store(path, []*sfnt.Font(nil))
+		return nil, fmt.Errorf("error parsing font %s: %w", path, err)
+	}
+
+	fonts := make([]*sfnt.Font, 0, collection.NumFonts())
+	for i := 0; i < collection.NumFonts(); i++ {
+		f, err := collection.Font(i)
+		if err != nil {
+			fontCache.Store(path, []*sfnt.Font(nil))
+			return nil, fmt.Errorf("error parsing font %s: %w", path, err)
+		}
+		fonts = append(fonts, f)
+	}
+
+	fontCache.Store(path, fonts)
+	return fonts, nil
+}
+
+// systemFonts lists the font files in the system font folders, sorted so the
+// choice of fallback font doesn't vary between runs
+func systemFonts() []string {
+	systemFontsOnce.Do(func() {
+		for _, dir := range systemFontDirs() {
+			filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() && fontExtensions[strings.ToLower(filepath.Ext(path))] {
+					systemFontPaths = append(systemFontPaths, path)
+				}
+				return nil
+			})
+		}
+		sort.Strings(systemFontPaths)
+	})
+	return systemFontPaths
+}
+
+func systemFontDirs() []string {
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "windows":
+		return []string{filepath.Join(os.Getenv("WINDIR"), "Fonts")}
+	case "darwin":
+		return []string{"/System/Library/Fonts", "/Library/Fonts", filepath.Join(home, "Library", "Fonts")}
+	default:
+		return []string{"/usr/share/fonts", "/usr/local/share/fonts", filepath.Join(home, ".local", "share", "fonts"), filepath.Join(home, ".fonts")}
+	}
+}
+
+// fallbackFace draws each character with the first of its fonts that has a
+// glyph for it. Metrics come from the first font. Like other faces it is not
+// safe for concurrent use, and it must not be used after Close.
+type fallbackFace struct {
+	chain  *FontChain
+	size   float64
+	fonts  []*sfnt.Font
+	faces  []font.Face
+	picked map[rune]font.Face
+	buf    sfnt.Buffer
+}
+
+func (f *fallbackFace) pick(r rune) font.Face {
+	if face, ok := f.picked[r]; ok {
+		return face
+	}
+
+	face := f.faces[0]
+	for i, ft := range f.fonts {
+		if hasGlyph(ft, &f.buf, r) {
+			face = f.faces[i]
+			break
+		}
+	}
+	f.picked[r] = face
+	return face
+}
+
+func (f *fallbackFace) Close() error {
+	f.chain.mutex.Lock()
+	defer f.chain.mutex.Unlock()
+	f.chain.release(f)
+	return nil
+}
+
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	return f.pick(r).Glyph(dot, r)
+}
+
+func (f *fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	return f.pick(r).GlyphBounds(r)
+}
+
+func (f *fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	return f.pick(r).GlyphAdvance(r)
+}
+
+func (f *fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if a := f.pick(r0); a == f.pick(r1) {
+		return a.Kern(r0, r1)
+	}
+	return 0
+}
+
+func (f *fallbackFace) Metrics() font.Metrics {
+	return f.faces[0].Metrics()
+}
@@ -0,0 +1,169 @@
+package screener
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestFontChainFallback(t *testing.T) {
+	const dejaVu = "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+	if _, err := os.Stat(dejaVu); err != nil {
+		t.Skip("DejaVu Sans not installed")
+	}
+
+	// The snowman is missing from Roboto but present in DejaVu Sans
+	const text = "snow ☃"
+	roboto, _ := NewFontChain()
+	if hasGlyph(roboto.fonts[0], &roboto.buf, '☃') {
+		t.Fatal("expected the embedded font to lack the test character")
+	}
+
+	chain, err := NewFontChain(dejaVu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	face, err := chain.Face(14, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+
+	// Latin text comes from the first font and the snowman gets a real glyph
+	if face.(*fallbackFace).pick('s') != face.(*fallbackFace).faces[0] {
+		t.Fatal("expected the first font to be used for covered characters")
+	}
+	if _, _, ok := face.GlyphBounds('☃'); !ok {
+		t.Fatal("expected a glyph for the snowman")
+	}
+	if font.MeasureString(face, text) <= font.MeasureString(face, "snow ") {
+		t.Fatal("expected the fallback glyph to have a width")
+	}
+
+	// Without user fonts it is found among the system fonts
+	system, err := roboto.Face(14, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer system.Close()
+	if _, _, ok := system.GlyphBounds('☃'); !ok {
+		t.Fatal("expected a system font to provide the snowman")
+	}
+}
+
+func TestFontChainCollection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go.ttc")
+	if err := os.WriteFile(path, fontCollection(goregular.TTF, gomono.TTF), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every font of the collection is used, ahead of the embedded font
+	chain, err := NewFontChain(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain.fonts) != 3 {
+		t.Fatalf("expected both fonts of the collection and the embedded font, got %d", len(chain.fonts))
+	}
+	face, err := chain.Face(14, "mono")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+	if _, _, ok := face.GlyphBounds('m'); !ok {
+		t.Fatal("expected a glyph from the collection")
+	}
+
+	if _, err := NewFontChain(filepath.Join(t.TempDir(), "missing.ttf")); err == nil {
+		t.Fatal("expected error for a missing font file")
+	}
+}
+
+func TestFontChainReusesFaces(t *testing.T) {
+	chain, err := NewFontChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := chain.Face(14, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ff := first.(*fallbackFace).faces[0]
+
+	// Faces in use at the same time don't share font faces
+	second, err := chain.Face(14, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.(*fallbackFace).faces[0] == ff {
+		t.Fatal("expected a face in use not to be handed out again")
+	}
+	second.Close()
+
+	// Once closed, a face of the same size is built from the same font faces
+	first.Close()
+	again, err := chain.Face(14, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	if again.(*fallbackFace).faces[0] != ff {
+		t.Fatal("expected the font face to be reused")
+	}
+
+	other, err := chain.Face(20, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if other.(*fallbackFace).faces[0] == ff {
+		t.Fatal("expected a new font face for another size")
+	}
+}
+
+// fontCollection builds a TrueType collection of fonts, moving the table
+// offsets of each font to where it ends up in the collection
+func fontCollection(fonts ...[]byte) []byte {
+	header := 12 + 4*len(fonts)
+	data := make([]byte, header)
+	copy(data, "ttcf")
+	binary.BigEndian.PutUint32(data[4:], 0x00010000)
+	binary.BigEndian.PutUint32(data[8:], uint32(len(fonts)))
+
+	for i, f := range fonts {
+		base := len(data)
+		binary.BigEndian.PutUint32(data[12+4*i:], uint32(base))
+
+		f = bytes.Clone(f)
+		numTables := int(binary.BigEndian.Uint16(f[4:]))
+		for t := 0; t < numTables; t++ {
+			offset := f[12+16*t+8:]
+			binary.BigEndian.PutUint32(offset, binary.BigEndian.Uint32(offset)+uint32(base))
+		}
+		data = append(data, f...)
+	}
+
+	return data
+}
+
+func TestDisplayIDN(t *testing.T) {
+	tests := map[string]string{
+		"https://xn--bcher-kva.example/path": "https://bücher.example/path (xn--bcher-kva.example)",
+		"https://bücher.example:8443/":       "https://bücher.example:8443/ (xn--bcher-kva.example)",
+		"https://example.com/xn--bcher-kva":  "https://example.com/xn--bcher-kva",
+		"https://XN--BCHER-KVA.example":      "https://bücher.example (xn--bcher-kva.example)",
+		"not a url":                          "not a url",
+	}
+	for in, want := range tests {
+		if got := displayIDN(in); got != want {
+			t.Errorf("%s: expected %q, got %q", in, want, got)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package screener
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnsupportedImage is returned for images that are neither PNG nor JPEG
+var ErrUnsupportedImage = errors.New("unsupported image format, expected PNG or JPEG")
+
+// PNG text keywords. Creation Time and Software are registered keywords.
+const (
+	keywordTargetURL    = "Target URL"
+	keywordLandingURL   = "Landing URL"
+	keywordStatus       = "Status"
+	keywordCreationTime = "Creation Time"
+	keywordSoftware     = "Software"
+	keywordSHA256       = "SHA-256"
+)
+
+// xmpNamespace holds the properties that have no standard XMP equivalent
+const xmpNamespace = "https://github.com/root4loot/screener/ns/1.0/"
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	xmpHeader    = []byte("http://ns.adobe.com/xap/1.0/\x00")
+)
+
+// ImageMetadata describes a capture inside the image file itself, so that
+// screenshots stay self-describing when copied out of the output folder
+type ImageMetadata struct {
+	TargetURL  string
+	LandingURL string
+	StatusCode int
+	CapturedAt time.Time
+	Software   string // name and version of the tool that made the capture
+	SHA256     string // SHA-256 of the image as captured, before annotation
+}
+
+// ImageMetadata returns the metadata embedded in the images of result
+func (result Result) ImageMetadata(software string) ImageMetadata {
+	return ImageMetadata{
+		TargetURL:  result.TargetURL,
+		LandingURL: result.LandingURL,
+		StatusCode: result.StatusCode,
+		CapturedAt: result.CapturedAt,
+		Software:   software,
+		SHA256:     result.RawSHA256,
+	}
+}
+
+// Fields returns the metadata as PNG keyword and value pairs in a fixed order,
+// leaving out empty values
+func (m ImageMetadata) Fields() [][2]string {
+	var fields [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, [2]string{key, value})
+		}
+	}
+
+	add(keywordTargetURL, m.TargetURL)
+	add(keywordLandingURL, m.LandingURL)
+	if m.StatusCode != 0 {
+		add(keywordStatus, strconv.Itoa(m.StatusCode))
+	}
+	if !m.CapturedAt.IsZero() {
+		add(keywordCreationTime, m.CapturedAt.UTC().Format(time.RFC3339))
+	}
+	add(keywordSoftware, m.Software)
+	add(keywordSHA256, m.SHA256)
+
+	return fields
+}
+
+// set assigns a field by its PNG keyword, ignoring unknown keywords
+func (m *ImageMetadata) set(key, value string) {
+	switch key {
+	case keywordTargetURL:
+		m.TargetURL = value
+	case keywordLandingURL:
+		m.LandingURL = value
+	case keywordStatus:
+		m.StatusCode, _ = strconv.Atoi(value)
+	case keywordCreationTime:
+		m.CapturedAt, _ = time.Parse(time.RFC3339, value)
+	case keywordSoftware:
+		m.Software = value
+	case keywordSHA256:
+		m.SHA256 = value
+	}
+}
+
+// EmbedMetadata returns the image with m written into it: as tEXt chunks, or
+// iTXt chunks for values that aren't Latin-1, in PNG images, and as an XMP
+// packet in JPEG images. Metadata embedded earlier is replaced.
+func (imgB Image) EmbedMetadata(m ImageMetadata) (Image, error) {
+	switch {
+	case bytes.HasPrefix(imgB, pngSignature):
+		return embedPNGMetadata(imgB, m)
+	case bytes.HasPrefix(imgB, []byte{0xff, 0xd8}):
+		return embedJPEGMetadata(imgB, m)
+	default:
+		return nil, ErrUnsupportedImage
+	}
+}
+
+// ReadImageMetadata reads the metadata embedded by EmbedMetadata, along with
+// any other PNG text chunks, which are returned in the order they appear
+func ReadImageMetadata(data []byte) (ImageMetadata, [][2]string, error) {
+	var m ImageMetadata
+	var texts [][2]string
+	var err error
+
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		texts, err = readPNGText(data)
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		texts, err = readJPEGXMP(data)
+	default:
+		err = ErrUnsupportedImage
+	}
+	if err != nil {
+		return m, nil, err
+	}
+
+	for _, kv := range texts {
+		m.set(kv[0], kv[1])
+	}
+	return m, texts, nil
+}
+
+func embedPNGMetadata(data []byte, m ImageMetadata) (Image, error) {
+	var chunks bytes.Buffer
+	for _, kv := range m.Fields() {
+		writePNGText(&chunks, kv[0], kv[1])
+	}
+
+	var out bytes.Buffer
+	out.Write(pngSignature)
+	err := walkPNG(data, func(typ string, chunk, body []byte) error {
+		if (typ == "tEXt" || typ == "iTXt" || typ == "zTXt") && isEmbeddedKeyword(body) {
+			return nil
+		}
+		out.Write(chunk)
+		// Text chunks go right after the header so readers find them early
+		if typ == "IHDR" {
+			out.Write(chunks.Bytes())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func isEmbeddedKeyword(body []byte) bool {
+	keyword, _, _ := bytes.Cut(body, []byte{0})
+	switch string(keyword) {
+	case keywordTargetURL, keywordLandingURL, keywordStatus, keywordCreationTime, keywordSoftware, keywordSHA256:
+		return true
+	}
+	return false
+}
+
+func writePNGText(w *bytes.Buffer, keyword, value string) {
+	typ, body := "tEXt", []byte(keyword+"\x00")
+	if latin1, ok := toLatin1(value); ok {
+		body = append(body, latin1...)
+	} else {
+		// Uncompressed, with empty language tag and translated keyword
+		typ = "iTXt"
+		body = append(body, 0, 0, 0, 0)
+		body = append(body, value...)
+	}
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(body)))
+	w.Write(length[:])
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(body)
+	w.WriteString(typ)
+	w.Write(body)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+func toLatin1(s string) ([]byte, bool) {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return nil, false
+		}
+		b = append(b, byte(r))
+	}
+	return b, true
+}
+
+// walkPNG calls fn with the type, raw bytes and body of each chunk of a PNG image
+func walkPNG(data []byte, fn func(typ string, chunk, body []byte) error) error {
+	for rest := data[len(pngSignature):]; len(rest) > 0; {
+		if len(rest) < 12 {
+			return errors.New("truncated PNG chunk")
+		}
+		length := binary.BigEndian.Uint32(rest)
+		if uint64(length) > uint64(len(rest)-12) {
+			return errors.New("truncated PNG chunk")
+		}
+		end := 12 + int(length)
+		if err := fn(string(rest[4:8]), rest[:end], rest[8:8+length]); err != nil {
+			return err
+		}
+		if string(rest[4:8]) == "IEND" {
+			return nil
+		}
+		rest = rest[end:]
+	}
+	return errors.New("missing PNG IEND chunk")
+}
+
+func readPNGText(data []byte) ([][2]string, error) {
+	var texts [][2]string
+	err := walkPNG(data, func(typ string, _, body []byte) error {
+		keyword, rest, _ := bytes.Cut(body, []byte{0})
+		var value []byte
+		switch typ {
+		case "tEXt":
+			value = latin1ToUTF8(rest)
+		case "zTXt":
+			if len(rest) < 1 {
+				return nil
+			}
+			text, err := inflate(rest[1:])
+			if err != nil {
+				return fmt.Errorf("error reading zTXt chunk %q: %w", keyword, err)
+			}
+			value = latin1ToUTF8(text)
+		case "iTXt":
+			if len(rest) < 2 {
+				return nil
+			}
+			compressed := rest[0] == 1
+			// Skip the language tag and translated keyword
+			parts := bytes.SplitN(rest[2:], []byte{0}, 3)
+			if len(parts) < 3 {
+				return nil
+			}
+			value = parts[2]
+			if compressed {
+				text, err := inflate(value)
+				if err != nil {
+					return fmt.Errorf("error reading iTXt chunk %q: %w", keyword, err)
+				}
+				value = text
+			}
+		default:
+			return nil
+		}
+		texts = append(texts, [2]string{string(latin1ToUTF8(keyword)), string(value)})
+		return nil
+	})
+	return texts, err
+}
+
+func latin1ToUTF8(b []byte) []byte {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return []byte(string(runes))
+}
+
+func inflate(b []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// xmpProperties maps PNG keywords to XMP properties. Standard properties are
+// used where they exist.
+var xmpProperties = []struct{ keyword, prefix, name string }{
+	{keywordTargetURL, "screener", "TargetURL"},
+	{keywordLandingURL, "screener", "LandingURL"},
+	{keywordStatus, "screener", "Status"},
+	{keywordCreationTime, "xmp", "CreateDate"},
+	{keywordSoftware, "xmp", "CreatorTool"},
+	{keywordSHA256, "screener", "SHA256"},
+}
+
+func xmpPacket(m ImageMetadata) []byte {
+	values := make(map[string]string)
+	for _, kv := range m.Fields() {
+		values[kv[0]] = kv[1]
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>` + "\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:screener="` + xmpNamespace + `"`)
+	for _, p := range xmpProperties {
+		if v, ok := values[p.keyword]; ok {
+			b.WriteString("\n  " + p.prefix + ":" + p.name + `="`)
+			xml.EscapeText(&b, []byte(v))
+			b.WriteString(`"`)
+		}
+	}
+	b.WriteString("/>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString(`<?xpacket end="r"?>`)
+	return b.Bytes()
+}
+
+// walkJPEG calls fn with the marker, raw bytes and payload of each segment
+// before the image data of a JPEG image, then returns the offset of the first
+// segment not walked
+func walkJPEG(data []byte, fn func(marker byte, segment, payload []byte)) (int, error) {
+	i := 2
+	for {
+		if i+4 > len(data) || data[i] != 0xff {
+			return 0, errors.New("invalid JPEG segment")
+		}
+		marker := data[i+1]
+		// Image data follows the start of scan, and the end of image has no length
+		if marker == 0xda || marker == 0xd9 {
+			return i, nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 0, errors.New("truncated JPEG segment")
+		}
+		fn(marker, data[i:i+2+length], data[i+4:i+2+length])
+		i += 2 + length
+	}
+}
+
+func embedJPEGMetadata(data []byte, m ImageMetadata) (Image, error) {
+	packet := append(append([]byte{}, xmpHeader...), xmpPacket(m)...)
+	if len(packet)+2 > 0xffff {
+		return nil, errors.New("metadata too large for a JPEG segment")
+	}
+
+	var segments bytes.Buffer
+	inserted := false
+	insert := func() {
+		segments.Write([]byte{0xff, 0xe1})
+		binary.Write(&segments, binary.BigEndian, uint16(len(packet)+2))
+		segments.Write(packet)
+		inserted = true
+	}
+
+	end, err := walkJPEG(data, func(marker byte, segment, payload []byte) {
+		if marker == 0xe1 && bytes.HasPrefix(payload, xmpHeader) {
+			return
+		}
+		// Keep the JFIF and Exif segments first, as readers expect
+		if !inserted && marker != 0xe0 && marker != 0xe1 {
+			insert()
+		}
+		segments.Write(segment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !inserted {
+		insert()
+	}
+
+	out := append([]byte{0xff, 0xd8}, segments.Bytes()...)
+	return append(out, data[end:]...), nil
+}
+
+func readJPEGXMP(data []byte) ([][2]string, error) {
+	var packet []byte
+	_, err := walkJPEG(data, func(marker byte, _, payload []byte) {
+		if marker == 0xe1 && bytes.HasPrefix(payload, xmpHeader) && packet == nil {
+			packet = payload[len(xmpHeader):]
+		}
+	})
+	if err != nil || packet == nil {
+		return nil, err
+	}
+
+	var texts [][2]string
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return texts, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading XMP packet: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "Description" {
+			continue
+		}
+		for _, attr := range start.Attr {
+			for _, p := range xmpProperties {
+				space := xmpNamespace
+				if p.prefix == "xmp" {
+					space = "http://ns.adobe.com/xap/1.0/"
+				}
+				if attr.Name.Space == space && attr.Name.Local == p.name {
+					texts = append(texts, [2]string{p.keyword, strings.TrimSpace(attr.Value)})
+				}
+			}
+		}
+	}
+}
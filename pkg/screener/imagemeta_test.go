@@ -0,0 +1,95 @@
+package screener
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func testImageMetadata() ImageMetadata {
+	return ImageMetadata{
+		TargetURL:  "https://пример.example/",
+		LandingURL: "https://xn--bcher-kva.example/login?next=%2F",
+		StatusCode: 200,
+		CapturedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Software:   "screener 0.1.0",
+		SHA256:     "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+}
+
+func TestEmbedPNGMetadata(t *testing.T) {
+	img := testPNG(t, 20, 10, color.White)
+	want := testImageMetadata()
+
+	embedded, err := img.EmbedMetadata(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(bytes.NewReader(embedded)); err != nil {
+		t.Fatalf("image no longer decodes: %v", err)
+	}
+
+	// Embedding again replaces the earlier metadata
+	want.StatusCode = 404
+	embedded, err = embedded.EmbedMetadata(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, texts, err := ReadImageMetadata(embedded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if len(texts) != len(want.Fields()) || texts[0] != [2]string{"Target URL", want.TargetURL} {
+		t.Fatalf("unexpected text chunks %q", texts)
+	}
+
+	// The Unicode URL needs an iTXt chunk, the rest fit in tEXt chunks
+	if !bytes.Contains(embedded, []byte("iTXtTarget URL")) || !bytes.Contains(embedded, []byte("tEXtLanding URL")) {
+		t.Fatal("expected an iTXt chunk for the target URL and tEXt for the landing URL")
+	}
+}
+
+func TestEmbedJPEGMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 20, 10)), nil); err != nil {
+		t.Fatal(err)
+	}
+	want := testImageMetadata()
+
+	embedded, err := Image(buf.Bytes()).EmbedMetadata(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if embedded, err = embedded.EmbedMetadata(want); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(embedded, xmpHeader); n != 1 {
+		t.Fatalf("expected one XMP packet, got %d", n)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(embedded)); err != nil {
+		t.Fatalf("image no longer decodes: %v", err)
+	}
+
+	got, _, err := ReadImageMetadata(embedded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestReadImageMetadataUnsupported(t *testing.T) {
+	if _, _, err := ReadImageMetadata([]byte("GIF89a")); !errors.Is(err, ErrUnsupportedImage) {
+		t.Fatalf("expected ErrUnsupportedImage, got %v", err)
+	}
+}
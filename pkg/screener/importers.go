@@ -0,0 +1,559 @@
+package screener
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/root4loot/goutils/log"
+	"github.com/root4loot/goutils/urlutil"
+)
+
+// Input formats accepted by ParseTargets
+const (
+	FormatPlain   = "plain"
+	FormatNmap    = "nmap"
+	FormatMasscan = "masscan"
+	FormatHTTPX   = "httpx"
+	FormatBurp    = "burp"
+	FormatZAP     = "zap"
+	FormatSitemap = "sitemap"
+)
+
+// Target is a URL to capture along with metadata about where it came from
+type Target struct {
+	URL      string
+	Metadata map[string]string
+	// Expanded is set for URLs made from a range, CIDR or port list, which
+	// already include the http:// URL wherever plain HTTP should be tried
+	Expanded bool
+}
+
+// ParseTargets parses r in the given input format
+func ParseTargets(format string, r io.Reader) ([]Target, error) {
+	switch format {
+	case FormatPlain, "":
+		return parsePlain(r)
+	case FormatNmap:
+		return ParseNmapXML(r)
+	case FormatMasscan:
+		return ParseMasscan(r)
+	case FormatHTTPX:
+		return ParseHTTPX(r)
+	case FormatBurp:
+		return ParseBurpXML(r)
+	case FormatZAP:
+		return ParseZAP(r)
+	case FormatSitemap:
+		return ParseSitemap(r)
+	default:
+		return nil, fmt.Errorf("unsupported input format %q", format)
+	}
+}
+
+func parsePlain(r io.Reader) ([]Target, error) {
+	var targets []Target
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		for _, field := range strings.Fields(scanner.Text()) {
+			targets = append(targets, Target{URL: field})
+		}
+	}
+	return targets, scanner.Err()
+}
+
+type nmapRun struct {
+	Hosts []struct {
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"hostnames>hostname"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   int    `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+			Service struct {
+				Name    string `xml:"name,attr"`
+				Tunnel  string `xml:"tunnel,attr"`
+				Product string `xml:"product,attr"`
+				Version string `xml:"version,attr"`
+			} `xml:"service"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+// ParseNmapXML builds targets from open HTTP services in nmap XML output (-oX)
+func ParseNmapXML(r io.Reader) ([]Target, error) {
+	var run nmapRun
+	if err := xml.NewDecoder(r).Decode(&run); err != nil {
+		return nil, fmt.Errorf("failed to parse nmap XML: %w", err)
+	}
+
+	var targets []Target
+	seen := make(map[string]bool)
+
+	for _, host := range run.Hosts {
+		var ip string
+		for _, addr := range host.Addresses {
+			if addr.AddrType == "ipv4" || addr.AddrType == "ipv6" {
+				ip = addr.Addr
+				break
+			}
+		}
+
+		// Prefer the name that was scanned over reverse DNS names
+		name := ip
+		for _, hn := range host.Hostnames {
+			if hn.Type == "user" {
+				name = hn.Name
+				break
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		for _, port := range host.Ports {
+			if port.Protocol != "tcp" || port.State.State != "open" {
+				continue
+			}
+
+			service := strings.ToLower(port.Service.Name)
+			if !strings.Contains(service, "http") {
+				continue
+			}
+
+			tls := port.Service.Tunnel == "ssl" || strings.HasPrefix(service, "https") || strings.HasPrefix(service, "ssl/")
+			u := serviceURL(name, port.PortID, schemeFor(tls, port.PortID))
+			if seen[u] {
+				continue
+			}
+			seen[u] = true
+
+			targets = append(targets, Target{URL: u, Metadata: compactMetadata(map[string]string{
+				"source":  FormatNmap,
+				"ip":      ip,
+				"port":    strconv.Itoa(port.PortID),
+				"service": port.Service.Name,
+				"tunnel":  port.Service.Tunnel,
+				"product": port.Service.Product,
+				"version": port.Service.Version,
+			})})
+		}
+	}
+
+	return targets, nil
+}
+
+type masscanRecord struct {
+	IP    string `json:"ip"`
+	Ports []struct {
+		Port   int    `json:"port"`
+		Proto  string `json:"proto"`
+		Status string `json:"status"`
+	} `json:"ports"`
+}
+
+// ParseMasscan builds targets from masscan JSON (-oJ, -oD) or list (-oL) output.
+// Masscan has no service detection, so only well-known web ports get a scheme;
+// other ports are left scheme-less to be tried over https and then http.
+func ParseMasscan(r io.Reader) ([]Target, error) {
+	var targets []Target
+	seen := make(map[string]bool)
+
+	add := func(ip string, port int) {
+		u := serviceURL(ip, port, schemeFor(false, port))
+		if port != 80 && port != 8080 && !isTLSPort(port) {
+			u = net.JoinHostPort(ip, strconv.Itoa(port))
+		}
+		if seen[u] {
+			return
+		}
+		seen[u] = true
+		targets = append(targets, Target{URL: u, Metadata: map[string]string{
+			"source": FormatMasscan,
+			"ip":     ip,
+			"port":   strconv.Itoa(port),
+		}})
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimSuffix(line, ",")
+
+		switch {
+		case line == "", line == "[", line == "]", strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "{"):
+			var rec masscanRecord
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				return nil, fmt.Errorf("failed to parse masscan JSON: %w", err)
+			}
+			for _, p := range rec.Ports {
+				if rec.IP != "" && p.Proto == "tcp" && p.Status == "open" {
+					add(rec.IP, p.Port)
+				}
+			}
+		default:
+			// open tcp 80 192.0.2.1 1700000000
+			fields := strings.Fields(line)
+			if len(fields) < 4 || fields[0] != "open" || fields[1] != "tcp" {
+				continue
+			}
+			port, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid masscan line %q", line)
+			}
+			add(fields[3], port)
+		}
+	}
+
+	return targets, scanner.Err()
+}
+
+// ParseHTTPX builds targets from httpx JSON lines output (-json)
+func ParseHTTPX(r io.Reader) ([]Target, error) {
+	var targets []Target
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, fmt.Errorf("failed to parse httpx JSON: %w", err)
+		}
+
+		u := metadataValue(rec["url"])
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+
+		meta := map[string]string{"source": FormatHTTPX}
+		for _, key := range []string{"input", "host", "port", "status_code", "title", "webserver", "content_length", "tech", "cdn_name"} {
+			meta[key] = metadataValue(rec[key])
+		}
+
+		targets = append(targets, Target{URL: u, Metadata: compactMetadata(meta)})
+	}
+
+	return targets, scanner.Err()
+}
+
+// metadataValue flattens a decoded JSON value into a string
+func metadataValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, metadataValue(item))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func compactMetadata(meta map[string]string) map[string]string {
+	for k, v := range meta {
+		if v == "" {
+			delete(meta, k)
+		}
+	}
+	return meta
+}
+
+func isTLSPort(port int) bool {
+	switch port {
+	case 443, 4443, 8443, 9443, 10443:
+		return true
+	}
+	return false
+}
+
+func schemeFor(tls bool, port int) string {
+	if tls || isTLSPort(port) {
+		return "https"
+	}
+	return "http"
+}
+
+// serviceURL builds a URL, leaving out the port when it is the scheme default
+func serviceURL(host string, port int, scheme string) string {
+	if (scheme == "http" && port == 80) || (scheme == "https" && port == 443) {
+		if strings.Contains(host, ":") {
+			return scheme + "://[" + host + "]"
+		}
+		return scheme + "://" + host
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+type burpItems struct {
+	Items []struct {
+		URL    string `xml:"url"`
+		Method string `xml:"method"`
+		Status string `xml:"status"`
+		Mime   string `xml:"mimetype"`
+		Host   struct {
+			IP string `xml:"ip,attr"`
+		} `xml:"host"`
+	} `xml:"item"`
+}
+
+// ParseBurpXML builds targets from the GET requests in a Burp Suite XML export
+func ParseBurpXML(r io.Reader) ([]Target, error) {
+	var items burpItems
+	if err := xml.NewDecoder(r).Decode(&items); err != nil {
+		return nil, fmt.Errorf("failed to parse Burp XML: %w", err)
+	}
+
+	var targets []Target
+	for _, item := range items.Items {
+		if item.Method != "" && item.Method != "GET" {
+			continue
+		}
+		targets = append(targets, Target{URL: strings.TrimSpace(item.URL), Metadata: compactMetadata(map[string]string{
+			"source":   FormatBurp,
+			"ip":       item.Host.IP,
+			"status":   item.Status,
+			"mimetype": item.Mime,
+		})})
+	}
+
+	return DedupeTargets(targets), nil
+}
+
+type zapReport struct {
+	Sites []struct {
+		Name   string `xml:"name,attr"`
+		Alerts []struct {
+			URIs []string `xml:"instances>instance>uri"`
+		} `xml:"alerts>alertitem"`
+	} `xml:"site"`
+}
+
+// ParseZAP builds targets from an OWASP ZAP XML report or a ZAP messages
+// export, which lists raw HTTP requests separated by "===" lines
+func ParseZAP(r io.Reader) ([]Target, error) {
+	br := bufio.NewReader(r)
+	if peek, _ := br.Peek(512); strings.HasPrefix(strings.TrimSpace(string(peek)), "<") {
+		return parseZAPReport(br)
+	}
+
+	var targets []Target
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		// GET https://example.com/path HTTP/1.1
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != "GET" || !strings.HasPrefix(fields[2], "HTTP/") {
+			continue
+		}
+		if strings.HasPrefix(fields[1], "http://") || strings.HasPrefix(fields[1], "https://") {
+			targets = append(targets, Target{URL: fields[1], Metadata: map[string]string{"source": FormatZAP}})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ZAP export: %w", err)
+	}
+
+	return DedupeTargets(targets), nil
+}
+
+func parseZAPReport(r io.Reader) ([]Target, error) {
+	var report zapReport
+	if err := xml.NewDecoder(r).Decode(&report); err != nil {
+		return nil, fmt.Errorf("failed to parse ZAP XML report: %w", err)
+	}
+
+	var targets []Target
+	for _, site := range report.Sites {
+		targets = append(targets, Target{URL: site.Name, Metadata: map[string]string{"source": FormatZAP}})
+		for _, alert := range site.Alerts {
+			for _, uri := range alert.URIs {
+				targets = append(targets, Target{URL: strings.TrimSpace(uri), Metadata: map[string]string{"source": FormatZAP}})
+			}
+		}
+	}
+
+	return DedupeTargets(targets), nil
+}
+
+// sitemapClient is the HTTP client used to fetch remote sitemaps
+var sitemapClient = &http.Client{Timeout: 30 * time.Second}
+
+// maxSitemapDepth bounds how many levels of nested sitemap indexes are followed
+const maxSitemapDepth = 3
+
+type sitemapDoc struct {
+	XMLName xml.Name
+	URLs    []string `xml:"url>loc"`
+	Maps    []string `xml:"sitemap>loc"`
+}
+
+// ParseSitemap builds targets from a sitemap or sitemap index. Sitemaps listed
+// in an index are fetched. Gzip compressed sitemaps are accepted.
+func ParseSitemap(r io.Reader) ([]Target, error) {
+	targets, err := parseSitemap(r, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	return DedupeTargets(targets), nil
+}
+
+// FetchSitemap fetches and parses the sitemap or sitemap index at sitemapURL
+func FetchSitemap(sitemapURL string) ([]Target, error) {
+	targets, err := fetchSitemap(sitemapURL, 0)
+	if err != nil {
+		return nil, err
+	}
+	return DedupeTargets(targets), nil
+}
+
+func fetchSitemap(sitemapURL string, depth int) ([]Target, error) {
+	resp, err := sitemapClient.Get(sitemapURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch sitemap %s: HTTP %d", sitemapURL, resp.StatusCode)
+	}
+
+	return parseSitemap(resp.Body, sitemapURL, depth)
+}
+
+func parseSitemap(r io.Reader, source string, depth int) ([]Target, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	var doc sitemapDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap: %w", err)
+	}
+
+	var targets []Target
+	for _, loc := range doc.URLs {
+		meta := map[string]string{"source": FormatSitemap}
+		if source != "" {
+			meta["sitemap"] = source
+		}
+		targets = append(targets, Target{URL: strings.TrimSpace(loc), Metadata: meta})
+	}
+
+	for _, loc := range doc.Maps {
+		loc = strings.TrimSpace(loc)
+		if depth >= maxSitemapDepth {
+			log.Warnf("Not following sitemap %s: nested too deep", loc)
+			continue
+		}
+
+		nested, err := fetchSitemap(loc, depth+1)
+		if err != nil {
+			log.Warnf("%v", err)
+			continue
+		}
+		targets = append(targets, nested...)
+	}
+
+	return targets, nil
+}
+
+// DedupeTargets removes targets whose normalized URLs were already seen, keeping the first
+func DedupeTargets(targets []Target) []Target {
+	seen := make(map[string]bool)
+	deduped := targets[:0]
+
+	for _, t := range targets {
+		key := NormalizeURL(t.URL)
+		if t.URL == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, t)
+	}
+
+	return deduped
+}
+
+// LimitPerHost keeps at most max targets per host, in input order. A max below 1 disables the limit.
+func LimitPerHost(targets []Target, max int) []Target {
+	if max < 1 {
+		return targets
+	}
+
+	counts := make(map[string]int)
+	limited := targets[:0]
+
+	for _, t := range targets {
+		host := t.URL
+		if u, err := url.Parse(t.URL); err == nil && u.Host != "" {
+			host = strings.ToLower(u.Hostname())
+		}
+		if counts[host] >= max {
+			continue
+		}
+		counts[host]++
+		limited = append(limited, t)
+	}
+
+	return limited
+}
+
+// NormalizeURL returns a canonical form of rawURL for comparing targets:
+// lowercase scheme and host, no default port, fragment or trailing slash
+func NormalizeURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(strings.TrimSpace(rawURL), "/")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	urlutil.RemoveDefaultPort(u)
+
+	return strings.TrimSuffix(u.String(), "/")
+}
@@ -0,0 +1,259 @@
+package screener
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func targetURLs(targets []Target) []string {
+	urls := make([]string, 0, len(targets))
+	for _, t := range targets {
+		urls = append(urls, t.URL)
+	}
+	return urls
+}
+
+func TestParseNmapXML(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<nmaprun scanner="nmap">
+  <host>
+    <address addr="192.0.2.10" addrtype="ipv4"/>
+    <hostnames>
+      <hostname name="www.example.com" type="user"/>
+      <hostname name="10.2.0.192.in-addr.arpa" type="PTR"/>
+    </hostnames>
+    <ports>
+      <port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port>
+      <port protocol="tcp" portid="80"><state state="open"/><service name="http" product="nginx" version="1.25"/></port>
+      <port protocol="tcp" portid="443"><state state="open"/><service name="http" tunnel="ssl"/></port>
+      <port protocol="tcp" portid="8443"><state state="open"/><service name="https-alt"/></port>
+      <port protocol="tcp" portid="8080"><state state="closed"/><service name="http-proxy"/></port>
+    </ports>
+  </host>
+  <host>
+    <address addr="192.0.2.11" addrtype="ipv4"/>
+    <ports>
+      <port protocol="tcp" portid="8000"><state state="open"/><service name="http-alt"/></port>
+    </ports>
+  </host>
+</nmaprun>`
+
+	targets, err := ParseNmapXML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "http://www.example.com,https://www.example.com,https://www.example.com:8443,http://192.0.2.11:8000"
+	if got := strings.Join(targetURLs(targets), ","); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	meta := targets[0].Metadata
+	if meta["source"] != "nmap" || meta["ip"] != "192.0.2.10" || meta["product"] != "nginx" || meta["port"] != "80" {
+		t.Fatalf("unexpected metadata %v", meta)
+	}
+}
+
+func TestParseMasscan(t *testing.T) {
+	jsonInput := `[
+{   "ip": "192.0.2.1",   "timestamp": "1700000000", "ports": [ {"port": 443, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 54} ] },
+{   "ip": "192.0.2.1",   "timestamp": "1700000000", "ports": [ {"port": 8081, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 54} ] },
+{   "ip": "192.0.2.2",   "timestamp": "1700000000", "ports": [ {"port": 53, "proto": "udp", "status": "open", "reason": "", "ttl": 54} ] },
+]`
+	listInput := `#masscan
+open tcp 80 192.0.2.3 1700000000
+banner tcp 80 192.0.2.3 1700000000 http HTTP/1.1 200 OK
+open tcp 80 192.0.2.3 1700000001
+# end
+`
+
+	targets, err := ParseMasscan(strings.NewReader(jsonInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(targetURLs(targets), ","), "https://192.0.2.1,192.0.2.1:8081"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	targets, err = ParseMasscan(strings.NewReader(listInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(targetURLs(targets), ","), "http://192.0.2.3"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if targets[0].Metadata["source"] != "masscan" || targets[0].Metadata["port"] != "80" {
+		t.Fatalf("unexpected metadata %v", targets[0].Metadata)
+	}
+}
+
+func TestParseHTTPX(t *testing.T) {
+	input := `{"timestamp":"2024-01-01T00:00:00Z","url":"https://example.com","input":"example.com","host":"93.184.216.34","port":"443","scheme":"https","status_code":200,"title":"Example Domain","webserver":"ECS","tech":["Nginx","HSTS"]}
+
+{"url":"http://example.org:8080","input":"example.org:8080","status_code":404}
+{"url":"https://example.com","input":"example.com"}
+`
+
+	targets, err := ParseHTTPX(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(targetURLs(targets), ","), "https://example.com,http://example.org:8080"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	meta := targets[0].Metadata
+	if meta["status_code"] != "200" || meta["title"] != "Example Domain" || meta["tech"] != "HSTS,Nginx" || meta["port"] != "443" {
+		t.Fatalf("unexpected metadata %v", meta)
+	}
+	if _, ok := targets[1].Metadata["title"]; ok {
+		t.Fatalf("expected empty fields to be dropped, got %v", targets[1].Metadata)
+	}
+}
+
+func TestParseBurpXML(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<items burpVersion="2024.1">
+  <item>
+    <url><![CDATA[https://example.com/login]]></url>
+    <host ip="192.0.2.1">example.com</host>
+    <method><![CDATA[GET]]></method>
+    <status>200</status>
+    <mimetype>HTML</mimetype>
+  </item>
+  <item>
+    <url><![CDATA[https://example.com/login]]></url>
+    <host ip="192.0.2.1">example.com</host>
+    <method><![CDATA[POST]]></method>
+  </item>
+  <item>
+    <url><![CDATA[https://EXAMPLE.com:443/login#top]]></url>
+    <method><![CDATA[GET]]></method>
+  </item>
+  <item>
+    <url><![CDATA[https://example.com/account]]></url>
+    <method><![CDATA[GET]]></method>
+  </item>
+</items>`
+
+	targets, err := ParseBurpXML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(targetURLs(targets), ","), "https://example.com/login,https://example.com/account"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if meta := targets[0].Metadata; meta["source"] != "burp" || meta["ip"] != "192.0.2.1" || meta["status"] != "200" {
+		t.Fatalf("unexpected metadata %v", meta)
+	}
+}
+
+func TestParseZAP(t *testing.T) {
+	messages := `===1 ==========
+GET https://example.com/ HTTP/1.1
+Host: example.com
+User-Agent: Mozilla/5.0
+
+HTTP/1.1 200 OK
+Content-Type: text/html
+
+<html></html>
+===2 ==========
+POST https://example.com/api HTTP/1.1
+Host: example.com
+
+===3 ==========
+GET https://example.com/about HTTP/1.1
+Host: example.com
+`
+	report := `<?xml version="1.0"?>
+<OWASPZAPReport version="2.14.0">
+  <site name="https://example.org" host="example.org" port="443" ssl="true">
+    <alerts>
+      <alertitem>
+        <instances>
+          <instance><uri>https://example.org/search?q=1</uri></instance>
+          <instance><uri>https://example.org</uri></instance>
+        </instances>
+      </alertitem>
+    </alerts>
+  </site>
+</OWASPZAPReport>`
+
+	targets, err := ParseZAP(strings.NewReader(messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(targetURLs(targets), ","), "https://example.com/,https://example.com/about"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	targets, err = ParseZAP(strings.NewReader(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(targetURLs(targets), ","), "https://example.org,https://example.org/search?q=1"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseSitemap(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%[1]s/pages.xml.gz</loc></sitemap>
+  <sitemap><loc>%[1]s/missing.xml</loc></sitemap>
+</sitemapindex>`, srv.URL)
+		case "/pages.xml.gz":
+			gz := gzip.NewWriter(w)
+			fmt.Fprint(gz, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc></url>
+  <url><loc> https://example.com/blog </loc></url>
+  <url><loc>https://example.com</loc></url>
+</urlset>`)
+			gz.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	targets, err := FetchSitemap(srv.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(targetURLs(targets), ","), "https://example.com/,https://example.com/blog"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if targets[0].Metadata["sitemap"] != srv.URL+"/pages.xml.gz" {
+		t.Fatalf("unexpected metadata %v", targets[0].Metadata)
+	}
+
+	// A local sitemap index fetches the sitemaps it lists
+	local := fmt.Sprintf(`<sitemapindex><sitemap><loc>%s/pages.xml.gz</loc></sitemap></sitemapindex>`, srv.URL)
+	targets, err = ParseSitemap(strings.NewReader(local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets from local index, got %v", targetURLs(targets))
+	}
+}
+
+func TestLimitPerHost(t *testing.T) {
+	targets := []Target{
+		{URL: "https://a.example/1"}, {URL: "https://a.example/2"}, {URL: "https://A.example/3"},
+		{URL: "https://b.example/1"}, {URL: "http://a.example:8080/4"},
+	}
+
+	got := strings.Join(targetURLs(LimitPerHost(targets, 2)), ",")
+	if want := "https://a.example/1,https://a.example/2,https://b.example/1"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
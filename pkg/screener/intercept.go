@@ -0,0 +1,126 @@
+package screener
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/root4loot/goutils/log"
+)
+
+// requestGuard records why the main frame navigation was blocked, if it was
+type requestGuard struct {
+	mutex   sync.Mutex
+	err     error
+	blocked int
+	proxy   *guardProxy
+	mainURL *url.URL
+}
+
+func (g *requestGuard) navigate(u *url.URL) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.mainURL = u
+}
+
+func (g *requestGuard) block(err error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.err == nil {
+		g.err = err
+	}
+}
+
+func (g *requestGuard) blockSubresource() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.blocked++
+}
+
+// Err returns the error that blocked the main frame, if any. This is either
+// a ScopeError or, when the guard proxy refused the latest main frame
+// destination, a DestinationError.
+func (g *requestGuard) Err() error {
+	if g == nil {
+		return nil
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.err != nil || g.mainURL == nil {
+		return g.err
+	}
+
+	port := g.mainURL.Port()
+	if port == "" {
+		port = "80"
+		if g.mainURL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return g.proxy.blockedErr(net.JoinHostPort(g.mainURL.Hostname(), port))
+}
+
+// Blocked returns the number of subresource requests and destinations that were blocked
+func (g *requestGuard) Blocked() int {
+	if g == nil {
+		return 0
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.blocked + g.proxy.Blocked()
+}
+
+// guardRequests pauses requests made by the page and fails those that are out
+// of scope. Main frame documents, including every redirect hop, are always
+// checked; other requests only when ScopeSubresources is set. The latest main
+// frame URL is tracked so that destinations refused by proxy can be reported.
+// A proxy that only resolves hosts refuses nothing and is ignored. Returns nil
+// when there is nothing to enforce.
+func (s *Screener) guardRequests(ctx context.Context, page *rod.Page, proxy *guardProxy, contextTag string) (*requestGuard, error) {
+	if proxy != nil && !proxy.blockPrivate {
+		proxy = nil
+	}
+	if s.CaptureOptions.Scope == nil && proxy == nil {
+		return nil, nil
+	}
+
+	pattern := &proto.FetchRequestPattern{URLPattern: "*", RequestStage: proto.FetchRequestStageRequest}
+	if !s.CaptureOptions.ScopeSubresources {
+		pattern.ResourceType = proto.NetworkResourceTypeDocument
+	}
+
+	if err := (proto.FetchEnable{Patterns: []*proto.FetchRequestPattern{pattern}}).Call(page); err != nil {
+		return nil, err
+	}
+
+	guard := &requestGuard{proxy: proxy}
+	wait := page.Context(ctx).EachEvent(func(e *proto.FetchRequestPaused) {
+		mainFrame := e.ResourceType == proto.NetworkResourceTypeDocument && e.FrameID == page.FrameID
+
+		u, err := url.Parse(e.Request.URL)
+		if err == nil && mainFrame {
+			guard.navigate(u)
+		}
+		if err == nil && (s.CaptureOptions.Scope.InScope(u) || (!mainFrame && !s.CaptureOptions.ScopeSubresources)) {
+			_ = proto.FetchContinueRequest{RequestID: e.RequestID}.Call(page)
+			return
+		}
+
+		if mainFrame {
+			log.Debugf("%s Blocking out of scope navigation to %q", contextTag, e.Request.URL)
+			guard.block(&ScopeError{URL: e.Request.URL})
+		} else {
+			log.Debugf("%s Blocking out of scope request to %q", contextTag, e.Request.URL)
+			guard.blockSubresource()
+		}
+
+		_ = proto.FetchFailRequest{RequestID: e.RequestID, ErrorReason: proto.NetworkErrorReasonBlockedByClient}.Call(page)
+	})
+	go wait()
+
+	return guard, nil
+}
@@ -0,0 +1,381 @@
+package screener
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBadSignature is returned when a manifest's signature doesn't match its content
+	ErrBadSignature = errors.New("manifest signature is invalid")
+	// ErrUntrustedKey is returned when a manifest is signed by a key other than the one expected
+	ErrUntrustedKey = errors.New("manifest is signed by a different key")
+	// ErrArtifactMissing is returned when an artifact listed in a manifest can't be found
+	ErrArtifactMissing = errors.New("artifact missing")
+	// ErrArtifactModified is returned when an artifact doesn't match its hash in a manifest
+	ErrArtifactModified = errors.New("artifact modified")
+)
+
+// Kinds of files written by a run outside its output
+const (
+	ArtifactReport   = "report"
+	ArtifactDatabase = "database"
+)
+
+// ManifestArtifact is a stored artifact with the capture it came from, if any.
+// Artifacts in the output of the run are named relative to it. Other files of
+// the run, such as reports, have a Kind and are named relative to the folder
+// of the manifest.
+type ManifestArtifact struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind,omitempty"`
+	URI        string `json:"uri"`
+	SHA256     string `json:"sha256"`
+	Size       int    `json:"size"`
+	Target     string `json:"target,omitempty"`
+	LandingURL string `json:"landing_url,omitempty"`
+	CapturedAt string `json:"captured_at,omitempty"`
+}
+
+// Manifest lists the artifacts stored during a run with their hashes. Signed
+// with a local Ed25519 key, it shows what was captured and when, and that the
+// artifacts haven't been changed since. It is safe for concurrent use.
+type Manifest struct {
+	Software   string             `json:"software"`
+	Output     string             `json:"output"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+	Artifacts  []ManifestArtifact `json:"artifacts"`
+	mutex      sync.Mutex
+}
+
+// signedManifest is the file format of a manifest. The signature covers the
+// compact JSON encoding of the manifest, so it doesn't depend on indentation.
+type signedManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	PublicKey string          `json:"public_key"`
+	Signature string          `json:"signature"`
+}
+
+// manifestStorage records everything put in the storage it wraps
+type manifestStorage struct {
+	Storage
+	manifest *Manifest
+}
+
+// NewManifest starts the manifest of a run storing artifacts in output, to be
+// written to manifestDir. An output folder is recorded relative to
+// manifestDir, like the files added with AddFile, so it can be found from
+// wherever the manifest is verified.
+func NewManifest(software, output, manifestDir string) (*Manifest, error) {
+	m := &Manifest{Software: software, Output: output, StartedAt: time.Now().UTC()}
+	if IsFolderOutput(output) {
+		rel, _, err := relativePath(output, manifestDir)
+		if err != nil {
+			return nil, err
+		}
+		m.Output = rel
+	}
+	return m, nil
+}
+
+// OutputDir returns the output folder of the run for a manifest read from
+// manifestDir
+func (m *Manifest) OutputDir(manifestDir string) string {
+	dir := filepath.FromSlash(m.Output)
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(manifestDir, dir)
+}
+
+// Wrap returns storage that adds each artifact put in it to the manifest
+func (m *Manifest) Wrap(storage Storage) Storage {
+	return &manifestStorage{Storage: storage, manifest: m}
+}
+
+func (s *manifestStorage) Put(ctx context.Context, name string, data []byte, metadata map[string]string) (string, error) {
+	uri, err := s.Storage.Put(ctx, name, data, metadata)
+	if err != nil {
+		return uri, err
+	}
+
+	digest := sha256.Sum256(data)
+	s.manifest.Add(ManifestArtifact{
+		Name:       name,
+		URI:        uri,
+		SHA256:     hex.EncodeToString(digest[:]),
+		Size:       len(data),
+		Target:     metadata["target-url"],
+		LandingURL: metadata["landing-url"],
+		CapturedAt: metadata["captured-at"],
+	})
+	return uri, nil
+}
+
+// Exists checks the wrapped storage, if it can tell
+func (s *manifestStorage) Exists(ctx context.Context, name string) (bool, error) {
+	if checker, ok := s.Storage.(NameChecker); ok {
+		return checker.Exists(ctx, name)
+	}
+	return false, nil
+}
+
+// Add adds an artifact, replacing any earlier artifact of the same name and kind
+func (m *Manifest) Add(artifact ManifestArtifact) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i := range m.Artifacts {
+		if m.Artifacts[i].Name == artifact.Name && m.Artifacts[i].Kind == artifact.Kind {
+			m.Artifacts[i] = artifact
+			return
+		}
+	}
+	m.Artifacts = append(m.Artifacts, artifact)
+}
+
+// AddFile adds the file at path, written by the run outside its output, as an
+// artifact of the given kind named relative to manifestDir
+func (m *Manifest) AddFile(kind, path, manifestDir string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	name, abs, err := relativePath(path, manifestDir)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(data)
+	m.Add(ManifestArtifact{
+		Name:   name,
+		Kind:   kind,
+		URI:    abs,
+		SHA256: hex.EncodeToString(digest[:]),
+		Size:   len(data),
+	})
+	return nil
+}
+
+// relativePath returns path relative to dir with forward slashes, and the
+// absolute path
+func relativePath(path, dir string) (string, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	rel, err := filepath.Rel(base, abs)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.ToSlash(rel), abs, nil
+}
+
+// Sign finishes the manifest and returns it signed with key, ready to be
+// written to a file. Artifacts in the output are listed first, by name,
+// followed by other files by kind and name.
+func (m *Manifest) Sign(key ed25519.PrivateKey) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.FinishedAt.IsZero() {
+		m.FinishedAt = time.Now().UTC()
+	}
+	if m.Artifacts == nil {
+		m.Artifacts = []ManifestArtifact{}
+	}
+	sort.Slice(m.Artifacts, func(i, j int) bool {
+		if m.Artifacts[i].Kind != m.Artifacts[j].Kind {
+			return m.Artifacts[i].Kind < m.Artifacts[j].Kind
+		}
+		return m.Artifacts[i].Name < m.Artifacts[j].Name
+	})
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := json.MarshalIndent(signedManifest{
+		Manifest:  payload,
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(signed, '\n'), nil
+}
+
+// VerifyManifest checks the signature of a manifest written by Sign and
+// returns the manifest and the key that signed it. When trusted is set, the
+// manifest must be signed by that key; otherwise any valid signature is
+// accepted, which only shows the manifest is intact.
+func VerifyManifest(data []byte, trusted ed25519.PublicKey) (*Manifest, ed25519.PublicKey, error) {
+	var signed signedManifest
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, nil, fmt.Errorf("error parsing manifest: %w", err)
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(signed.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, nil, errors.New("manifest has an invalid public key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil {
+		return nil, nil, ErrBadSignature
+	}
+
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, signed.Manifest); err != nil {
+		return nil, nil, fmt.Errorf("error parsing manifest: %w", err)
+	}
+	if !ed25519.Verify(publicKey, payload.Bytes(), signature) {
+		return nil, nil, ErrBadSignature
+	}
+	if trusted != nil && !bytes.Equal(trusted, publicKey) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUntrustedKey, KeyFingerprint(publicKey))
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(signed.Manifest, &m); err != nil {
+		return nil, nil, fmt.Errorf("error parsing manifest: %w", err)
+	}
+
+	return &m, publicKey, nil
+}
+
+// VerifyArtifact checks that the artifact stored under dir matches its hash.
+// dir is the output of the run for artifacts without a Kind, and the folder of
+// the manifest for the others.
+func VerifyArtifact(dir string, artifact ManifestArtifact) error {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(artifact.Name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrArtifactMissing
+	}
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(data)
+	if hex.EncodeToString(digest[:]) != artifact.SHA256 {
+		return ErrArtifactModified
+	}
+	return nil
+}
+
+// KeyFingerprint identifies a public key in the form SHA256:<base64>, as
+// OpenSSH does
+func KeyFingerprint(key ed25519.PublicKey) string {
+	digest := sha256.Sum256(key)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(digest[:])
+}
+
+// LoadOrCreateSigningKey reads the PEM encoded Ed25519 private key at path.
+// If there is no such file a key is generated and saved there, readable only
+// by the owner, with the public key next to it in path.pub.
+func LoadOrCreateSigningKey(path string) (key ed25519.PrivateKey, created bool, err error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, false, fmt.Errorf("error reading key %s: %w", path, err)
+		}
+		return key, false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+
+	publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, false, err
+	}
+
+	der, err = x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.WriteFile(path+".pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644); err != nil {
+		return nil, false, err
+	}
+
+	return key, true, nil
+}
+
+// LoadPublicKey reads a PEM encoded Ed25519 public key, or the public half of
+// a private key
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM encoded key", path)
+	}
+	if block.Type == "PRIVATE KEY" {
+		key, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("error reading key %s: %w", path, err)
+		}
+		return key.Public().(ed25519.PublicKey), nil
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key %s: %w", path, err)
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 key", path)
+	}
+	return key, nil
+}
+
+func parsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("not a PEM encoded private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 key")
+	}
+	return key, nil
+}
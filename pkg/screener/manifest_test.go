@@ -0,0 +1,136 @@
+package screener
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifestDir := t.TempDir()
+	manifest, err := NewManifest("screener 0.1.0", dir, manifestDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.OutputDir(manifestDir) != dir {
+		t.Fatalf("expected the output to resolve to %s, got %s", dir, manifest.OutputDir(manifestDir))
+	}
+	storage := manifest.Wrap(&FileStorage{Dir: dir})
+
+	ctx := context.Background()
+	metadata := map[string]string{"target-url": "https://example.com", "captured-at": "2024-05-01T12:30:00Z"}
+	for _, name := range []string{"b.png", "a/a.png", "b.png"} {
+		if _, err := storage.Put(ctx, name, []byte("data of "+name), metadata); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A report written next to the manifest, outside the output
+	report := filepath.Join(manifestDir, "reports", "report.csv")
+	os.MkdirAll(filepath.Dir(report), 0o755)
+	os.WriteFile(report, []byte("url\n"), 0o644)
+	if err := manifest.AddFile(ArtifactReport, report, manifestDir); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPath := filepath.Join(dir, "screener.key")
+	key, created, err := LoadOrCreateSigningKey(keyPath)
+	if err != nil || !created {
+		t.Fatalf("expected a new key, got created=%v: %v", created, err)
+	}
+	if info, err := os.Stat(keyPath); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the key to be readable by the owner only: %v", err)
+	}
+	if again, created, err := LoadOrCreateSigningKey(keyPath); err != nil || created || !again.Equal(key) {
+		t.Fatalf("expected the saved key to be loaded: %v", err)
+	}
+	publicKey, err := LoadPublicKey(keyPath + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := manifest.Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, signer, err := VerifyManifest(signed, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyFingerprint(signer) != KeyFingerprint(publicKey) {
+		t.Fatal("expected the manifest to report its signer")
+	}
+	if len(verified.Artifacts) != 3 || verified.Artifacts[0].Name != "a/a.png" || verified.Artifacts[1].Target != "https://example.com" {
+		t.Fatalf("unexpected artifacts %+v", verified.Artifacts)
+	}
+	if a := verified.Artifacts[2]; a.Name != "reports/report.csv" || a.Kind != ArtifactReport {
+		t.Fatalf("expected the report to be listed last, relative to the manifest, got %+v", a)
+	}
+	for _, a := range verified.Artifacts[:2] {
+		if err := VerifyArtifact(dir, a); err != nil {
+			t.Fatalf("%s: %v", a.Name, err)
+		}
+	}
+	if err := VerifyArtifact(manifestDir, verified.Artifacts[2]); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+
+	// Changed and deleted artifacts are detected
+	os.WriteFile(filepath.Join(dir, "b.png"), []byte("changed"), 0o644)
+	if err := VerifyArtifact(dir, verified.Artifacts[1]); !errors.Is(err, ErrArtifactModified) {
+		t.Fatalf("expected ErrArtifactModified, got %v", err)
+	}
+	os.Remove(filepath.Join(dir, "a", "a.png"))
+	if err := VerifyArtifact(dir, verified.Artifacts[0]); !errors.Is(err, ErrArtifactMissing) {
+		t.Fatalf("expected ErrArtifactMissing, got %v", err)
+	}
+
+	// So are changes to the manifest, but not to its formatting
+	tampered := bytes.Replace(signed, []byte("https://example.com"), []byte("https://example.org"), 1)
+	if _, _, err := VerifyManifest(tampered, nil); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected ErrBadSignature, got %v", err)
+	}
+	reformatted := bytes.ReplaceAll(signed, []byte("\n"), []byte("\r\n"))
+	if _, _, err := VerifyManifest(reformatted, nil); err != nil {
+		t.Fatalf("expected reformatted manifest to verify: %v", err)
+	}
+
+	// And manifests signed by another key
+	other, _, err := LoadOrCreateSigningKey(filepath.Join(dir, "other.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := VerifyManifest(signed, other.Public().(ed25519.PublicKey)); !errors.Is(err, ErrUntrustedKey) {
+		t.Fatalf("expected ErrUntrustedKey, got %v", err)
+	}
+}
+
+func TestManifestOutput(t *testing.T) {
+	// A relative output is recorded relative to the manifest, so it is found
+	// from any working directory
+	manifest, err := NewManifest("screener 0.1.0", "screenshots", "evidence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Output != "../screenshots" {
+		t.Fatalf("expected the output relative to the manifest, got %s", manifest.Output)
+	}
+	manifestDir := filepath.Join(t.TempDir(), "evidence")
+	if want := filepath.Join(filepath.Dir(manifestDir), "screenshots"); manifest.OutputDir(manifestDir) != want {
+		t.Fatalf("expected %s, got %s", want, manifest.OutputDir(manifestDir))
+	}
+
+	// Archives and buckets are recorded as given
+	for _, output := range []string{"screenshots.zip", "s3://bucket/prefix"} {
+		manifest, err := NewManifest("screener 0.1.0", output, "evidence")
+		if err != nil || manifest.Output != output {
+			t.Fatalf("expected %s to be recorded as given, got %s: %v", output, manifest.Output, err)
+		}
+	}
+}
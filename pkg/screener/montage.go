@@ -0,0 +1,255 @@
+package screener
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/glaslos/ssdeep"
+	"github.com/root4loot/goutils/log"
+)
+
+// Montage defaults
+const (
+	DefaultMontageColumns   = 4
+	DefaultMontageRows      = 3
+	DefaultMontageTileWidth = 320
+	// DefaultClusterThreshold is looser than duplicate detection so that
+	// pages sharing a layout, such as default server pages, end up together
+	DefaultClusterThreshold = 50
+)
+
+// MontageOptions configures contact sheets
+type MontageOptions struct {
+	Columns             int
+	Rows                int
+	TileWidth           int  // tiles are 4:3, showing the top of each screenshot
+	GroupBySimilarity   bool // one cluster of similar screenshots per sheet
+	SimilarityThreshold int  // ssdeep score from 1 to 100 to join a cluster, defaults to DefaultClusterThreshold
+}
+
+// Montage is a contact sheet of screenshots. Cluster is 0 unless grouping by
+// similarity, in which case clusters are numbered from 1.
+type Montage struct {
+	Image   Image
+	Cluster int
+	Page    int
+	Pages   int
+	URLs    []string
+}
+
+// Name returns a file name for the montage such as montage-001.png or
+// montage-c02-001.png
+func (m Montage) Name() string {
+	if m.Cluster > 0 {
+		return fmt.Sprintf("montage-c%02d-%03d.png", m.Cluster, m.Page)
+	}
+	return fmt.Sprintf("montage-%03d.png", m.Page)
+}
+
+// ParseGrid parses a grid size such as 4x3 as columns and rows
+func ParseGrid(s string) (columns, rows int, err error) {
+	c, r, ok := strings.Cut(strings.ToLower(s), "x")
+	if ok {
+		columns, err = strconv.Atoi(c)
+		if err == nil {
+			rows, err = strconv.Atoi(r)
+		}
+	}
+	if !ok || err != nil || columns < 1 || rows < 1 {
+		return 0, 0, fmt.Errorf("invalid grid %q, expected columns x rows such as 4x3", s)
+	}
+	return columns, rows, nil
+}
+
+// ClusterResults groups results whose images are at least threshold similar
+// to the first image of a cluster. Clusters keep the order of results.
+func ClusterResults(results []Result, threshold int) [][]Result {
+	var clusters [][]Result
+	var hashes []string
+
+	for _, result := range results {
+		hash, err := ssdeep.FuzzyBytes(result.Image)
+		joined := false
+		if err == nil {
+			for i, h := range hashes {
+				if h == "" {
+					continue
+				}
+				if score, err := ssdeep.Distance(hash, h); err == nil && score >= threshold {
+					clusters[i] = append(clusters[i], result)
+					joined = true
+					break
+				}
+			}
+		}
+		if !joined {
+			clusters = append(clusters, []Result{result})
+			hashes = append(hashes, hash)
+		}
+	}
+
+	return clusters
+}
+
+// BuildMontages lays out the screenshots of results on contact sheets of
+// Columns x Rows tiles, each labelled with its URL. Results without an image
+// are left out.
+func BuildMontages(results []Result, opts MontageOptions) ([]Montage, error) {
+	if opts.Columns < 1 {
+		opts.Columns = DefaultMontageColumns
+	}
+	if opts.Rows < 1 {
+		opts.Rows = DefaultMontageRows
+	}
+	if opts.TileWidth < 1 {
+		opts.TileWidth = DefaultMontageTileWidth
+	}
+	if opts.SimilarityThreshold == 0 {
+		opts.SimilarityThreshold = DefaultClusterThreshold
+	}
+	if opts.SimilarityThreshold < 1 || opts.SimilarityThreshold > 100 {
+		return nil, fmt.Errorf("invalid similarity threshold %d, must be between 1 and 100", opts.SimilarityThreshold)
+	}
+
+	var withImages []Result
+	for _, result := range results {
+		if len(result.Image) > 0 {
+			withImages = append(withImages, result)
+		}
+	}
+
+	groups := [][]Result{withImages}
+	if opts.GroupBySimilarity {
+		groups = ClusterResults(withImages, opts.SimilarityThreshold)
+	}
+
+	perSheet := opts.Columns * opts.Rows
+	var montages []Montage
+	for i, group := range groups {
+		pages := (len(group) + perSheet - 1) / perSheet
+		for page := 0; page < pages; page++ {
+			end := min((page+1)*perSheet, len(group))
+			m := Montage{Page: page + 1, Pages: pages}
+			if opts.GroupBySimilarity {
+				m.Cluster = i + 1
+			}
+
+			img, err := m.draw(group[page*perSheet:end], opts)
+			if err != nil {
+				return nil, err
+			}
+			m.Image = img
+			montages = append(montages, m)
+		}
+	}
+
+	return montages, nil
+}
+
+func (m *Montage) draw(results []Result, opts MontageOptions) (Image, error) {
+	const padding = 10
+	const headerHeight = 30
+	const labelHeight = 24
+
+	tileWidth := opts.TileWidth
+	tileHeight := tileWidth * 3 / 4
+	rows := (len(results) + opts.Columns - 1) / opts.Columns
+
+	w := opts.Columns*(tileWidth+padding) + padding
+	h := headerHeight + rows*(tileHeight+labelHeight+padding) + padding
+	dc := gg.NewContext(w, h)
+
+	dc.SetColor(color.White)
+	dc.Clear()
+	labels := make([]string, len(results))
+	for i, result := range results {
+		labels[i] = result.TargetURL
+	}
+	fonts, err := defaultFontChain()
+	if err != nil {
+		return nil, err
+	}
+	face, err := fonts.Face(14, labels...)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+	dc.SetFontFace(face)
+
+	header := fmt.Sprintf("page %d/%d", m.Page, m.Pages)
+	if m.Cluster > 0 {
+		header = fmt.Sprintf("cluster %d, %s", m.Cluster, header)
+	}
+	dc.SetColor(color.Black)
+	dc.DrawStringAnchored(header, padding, headerHeight/2, 0, 0.35)
+
+	for i, result := range results {
+		x := float64(padding + (i%opts.Columns)*(tileWidth+padding))
+		y := float64(headerHeight + (i/opts.Columns)*(tileHeight+labelHeight+padding))
+
+		img, err := png.Decode(bytes.NewReader(result.Image))
+		if err != nil {
+			log.Debugf("Leaving %s out of montage: %v", result.TargetURL, err)
+			continue
+		}
+		m.URLs = append(m.URLs, result.TargetURL)
+
+		// Only the top of the screenshot that fits in the tile is drawn
+		scale := float64(tileWidth) / float64(img.Bounds().Dx())
+		visible := img.Bounds()
+		visible.Max.Y = min(visible.Max.Y, visible.Min.Y+int(float64(tileHeight)/scale)+1)
+		if sub, ok := img.(interface {
+			SubImage(image.Rectangle) image.Image
+		}); ok {
+			img = sub.SubImage(visible)
+		}
+
+		dc.Push()
+		dc.DrawRectangle(x, y, float64(tileWidth), float64(tileHeight))
+		dc.Clip()
+		dc.Translate(x, y)
+		dc.Scale(scale, scale)
+		dc.DrawImage(img, -visible.Min.X, -visible.Min.Y)
+		dc.Pop()
+		dc.ResetClip()
+
+		dc.SetColor(color.Gray{Y: 160})
+		dc.SetLineWidth(1)
+		dc.DrawRectangle(x+0.5, y+0.5, float64(tileWidth)-1, float64(tileHeight)-1)
+		dc.Stroke()
+
+		dc.SetColor(color.Black)
+		label := fitString(dc, result.TargetURL, float64(tileWidth))
+		dc.DrawStringAnchored(label, x+float64(tileWidth)/2, y+float64(tileHeight)+labelHeight/2, 0.5, 0.35)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dc.Image()); err != nil {
+		return nil, fmt.Errorf("failed to encode montage: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// fitString shortens s with an ellipsis until it fits in width
+func fitString(dc *gg.Context, s string, width float64) string {
+	if w, _ := dc.MeasureString(s); w <= width {
+		return s
+	}
+
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := string(runes) + "…"
+		if w, _ := dc.MeasureString(candidate); w <= width {
+			return candidate
+		}
+	}
+	return ""
+}
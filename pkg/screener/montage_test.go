@@ -0,0 +1,100 @@
+package screener
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+func testPNG(t *testing.T, w, h int, c color.Color) Image {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBuildMontages(t *testing.T) {
+	var results []Result
+	for i := 0; i < 5; i++ {
+		results = append(results, Result{
+			TargetURL: fmt.Sprintf("https://host%d.example.com/a/very/long/path/that/does/not/fit/under/the/tile", i),
+			Image:     testPNG(t, 1024, 2000, color.RGBA{R: uint8(i * 40), A: 255}),
+		})
+	}
+	results = append(results, Result{TargetURL: "https://noimage.example.com"})
+
+	montages, err := BuildMontages(results, MontageOptions{Columns: 2, Rows: 2, TileWidth: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(montages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(montages))
+	}
+	if len(montages[0].URLs) != 4 || len(montages[1].URLs) != 1 || montages[1].Page != 2 || montages[1].Pages != 2 {
+		t.Fatalf("unexpected pagination: %+v", montages)
+	}
+	if montages[0].Name() != "montage-001.png" {
+		t.Fatalf("unexpected name %s", montages[0].Name())
+	}
+
+	img, err := png.Decode(bytes.NewReader(montages[0].Image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 columns of 200px tiles with 10px padding; 2 rows of 150px tiles and labels below a header
+	if img.Bounds().Dx() != 430 || img.Bounds().Dy() != 30+2*(150+24+10)+10 {
+		t.Fatalf("unexpected montage size %v", img.Bounds())
+	}
+
+	// The last page only has as many rows as it needs
+	img, _ = png.Decode(bytes.NewReader(montages[1].Image))
+	if img.Bounds().Dy() != 30+150+24+10+10 {
+		t.Fatalf("unexpected last page size %v", img.Bounds())
+	}
+}
+
+func TestClusterResults(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rng.Read(b)
+		return b
+	}
+
+	a := random(32 * 1024)
+	similar := append(append([]byte{}, a[:31*1024]...), random(1024)...)
+	other := random(32 * 1024)
+
+	clusters := ClusterResults([]Result{
+		{TargetURL: "a", Image: a},
+		{TargetURL: "other", Image: other},
+		{TargetURL: "similar", Image: similar},
+	}, 50)
+
+	if len(clusters) != 2 || len(clusters[0]) != 2 || clusters[0][1].TargetURL != "similar" || clusters[1][0].TargetURL != "other" {
+		t.Fatalf("unexpected clusters %v", clusters)
+	}
+}
+
+func TestParseGrid(t *testing.T) {
+	if c, r, err := ParseGrid("5X4"); err != nil || c != 5 || r != 4 {
+		t.Fatalf("expected 5x4, got %dx%d: %v", c, r, err)
+	}
+	for _, s := range []string{"", "4", "0x3", "ax3", "4x-1"} {
+		if _, _, err := ParseGrid(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package screener
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket allows rate events per second on average with bursts of up to one
+// second's worth of events. Waiters reserve tokens in arrival order.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (b *tokenBucket) reserve() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a token taken by reserve that was not used
+func (b *tokenBucket) cancel() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens++
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+
+	delay := b.reserve()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
+
+// RateLimiter limits captures globally and per host. It is safe for
+// concurrent use and meant to be shared by every worker.
+type RateLimiter struct {
+	global          *tokenBucket
+	hostRate        float64
+	hostConcurrency int
+	mutex           sync.Mutex
+	hosts           map[string]*hostLimit
+	sweepAt         int // number of hosts at which idle hosts are evicted
+}
+
+// minSweep is the number of hosts tracked before idle ones are evicted
+const minSweep = 64
+
+type hostLimit struct {
+	bucket *tokenBucket
+	slots  chan struct{}
+	active int       // captures waiting or in progress
+	last   time.Time // when the last capture finished
+}
+
+// idle reports whether h can be dropped without changing how later captures
+// are limited: nothing is waiting on it and its bucket has refilled
+func (h *hostLimit) idle(now time.Time) bool {
+	if h.active > 0 {
+		return false
+	}
+	if h.bucket == nil {
+		return true
+	}
+	refill := time.Duration(h.bucket.burst / h.bucket.rate * float64(time.Second))
+	return now.Sub(h.last) >= refill
+}
+
+// NewRateLimiter returns a limiter allowing rate captures per second overall,
+// hostRate captures per second and hostConcurrency simultaneous captures per
+// host. Zero disables the respective limit.
+func NewRateLimiter(rate, hostRate float64, hostConcurrency int) *RateLimiter {
+	l := &RateLimiter{
+		hostRate:        hostRate,
+		hostConcurrency: hostConcurrency,
+		hosts:           make(map[string]*hostLimit),
+		sweepAt:         minSweep,
+	}
+	if rate > 0 {
+		l.global = newTokenBucket(rate)
+	}
+	return l
+}
+
+// acquire returns the limits of hostname, marking it active until done is called
+func (l *RateLimiter) acquire(hostname string) *hostLimit {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	hostname = strings.ToLower(hostname)
+	h, ok := l.hosts[hostname]
+	if !ok {
+		l.sweep()
+		h = &hostLimit{}
+		if l.hostRate > 0 {
+			h.bucket = newTokenBucket(l.hostRate)
+		}
+		if l.hostConcurrency > 0 {
+			h.slots = make(chan struct{}, l.hostConcurrency)
+		}
+		l.hosts[hostname] = h
+	}
+	h.active++
+	return h
+}
+
+func (l *RateLimiter) done(h *hostLimit) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	h.active--
+	h.last = time.Now()
+}
+
+// sweep evicts idle hosts once enough are tracked, so that large scans don't
+// keep a limit for every host they have seen. Sweeps are spaced out so their
+// cost stays proportional to the number of hosts added.
+func (l *RateLimiter) sweep() {
+	if len(l.hosts) < l.sweepAt {
+		return
+	}
+
+	now := time.Now()
+	for hostname, h := range l.hosts {
+		if h.idle(now) {
+			delete(l.hosts, hostname)
+		}
+	}
+	l.sweepAt = max(minSweep, 2*len(l.hosts))
+}
+
+// Wait blocks until a capture of hostname is allowed. The returned function
+// must be called once the capture is done to free the host's slot.
+func (l *RateLimiter) Wait(ctx context.Context, hostname string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	h := l.acquire(hostname)
+	release = func() { l.done(h) }
+
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+			release = func() {
+				<-h.slots
+				l.done(h)
+			}
+		case <-ctx.Done():
+			l.done(h)
+			return nil, ctx.Err()
+		}
+	}
+
+	if err := h.bucket.wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+
+	if err := l.global.wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// rateLimiter returns the limiter for the configured rate limits, creating it on first use
+func (s *Screener) rateLimiter() *RateLimiter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.limiter == nil && (s.CaptureOptions.RateLimit > 0 || s.CaptureOptions.HostRateLimit > 0 || s.CaptureOptions.HostConcurrency > 0) {
+		s.limiter = NewRateLimiter(s.CaptureOptions.RateLimit, s.CaptureOptions.HostRateLimit, s.CaptureOptions.HostConcurrency)
+	}
+
+	return s.limiter
+}
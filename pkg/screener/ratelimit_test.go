@@ -0,0 +1,108 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterGlobalRate(t *testing.T) {
+	l := NewRateLimiter(20, 0, 0)
+
+	start := time.Now()
+	for i := 0; i < 30; i++ {
+		release, err := l.Wait(context.Background(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+
+	// The first 20 are a burst, the remaining 10 take half a second
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("expected about 500ms for 30 captures at 20/s, took %v", elapsed)
+	}
+}
+
+func TestRateLimiterHostConcurrency(t *testing.T) {
+	l := NewRateLimiter(0, 0, 1)
+
+	release, err := l.Wait(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Other hosts are not affected
+	other, err := l.Wait(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Wait(ctx, "EXAMPLE.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected second capture of the same host to block, got %v", err)
+	}
+
+	release()
+	release, err = l.Wait(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestRateLimiterHostRate(t *testing.T) {
+	l := NewRateLimiter(0, 2, 0)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := l.Wait(context.Background(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("expected third capture to wait for the host rate, took %v", elapsed)
+	}
+
+	start = time.Now()
+	release, _ := l.Wait(context.Background(), "example.org")
+	release()
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("expected other host to be unaffected, took %v", elapsed)
+	}
+}
+
+func TestRateLimiterEvictsIdleHosts(t *testing.T) {
+	l := NewRateLimiter(0, 0, 1)
+
+	held, err := l.Wait(context.Background(), "busy.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		release, err := l.Wait(context.Background(), fmt.Sprintf("host%d.example.com", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+
+	if n := len(l.hosts); n > 2*minSweep {
+		t.Fatalf("expected idle hosts to be evicted, %d tracked", n)
+	}
+
+	// Hosts with captures in progress keep their limits
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Wait(ctx, "busy.example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected busy host to still be limited, got %v", err)
+	}
+	held()
+}
@@ -0,0 +1,271 @@
+package screener
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report columns
+const (
+	ColumnURL        = "url"
+	ColumnLandingURL = "landing_url"
+	ColumnHost       = "host"
+	ColumnStatus     = "status"
+	ColumnTitle      = "title"
+	ColumnError      = "error"
+	ColumnAttempts   = "attempts"
+	ColumnCapturedAt = "captured_at"
+	ColumnDuration   = "duration"
+	ColumnImage      = "image"
+	ColumnThumbnail  = "thumbnail"
+)
+
+// Report sort orders
+const (
+	SortByHost   = "host"
+	SortByStatus = "status"
+)
+
+// DefaultReportColumns are used when no columns are given
+var DefaultReportColumns = []string{ColumnURL, ColumnStatus, ColumnTitle, ColumnImage}
+
+var reportColumns = map[string]string{
+	ColumnURL:        "URL",
+	ColumnLandingURL: "Landing URL",
+	ColumnHost:       "Host",
+	ColumnStatus:     "Status",
+	ColumnTitle:      "Title",
+	ColumnError:      "Error",
+	ColumnAttempts:   "Attempts",
+	ColumnCapturedAt: "Captured At",
+	ColumnDuration:   "Duration",
+	ColumnImage:      "Image",
+	ColumnThumbnail:  "Thumbnail",
+}
+
+// ReportOptions configures CSV and Markdown reports
+type ReportOptions struct {
+	Columns   []string // defaults to DefaultReportColumns
+	SortBy    string   // SortByHost, SortByStatus or empty to keep capture order
+	ImageBase string   // directory local image paths are made relative to
+}
+
+// ParseReportColumns parses a comma separated list of report columns
+func ParseReportColumns(s string) ([]string, error) {
+	var columns []string
+	for _, c := range strings.Split(s, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if _, ok := reportColumns[c]; !ok {
+			return nil, fmt.Errorf("unknown report column %q", c)
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// WriteCSV writes results as CSV with a header row. Values that a spreadsheet
+// would read as a formula, such as page titles starting with =, are prefixed
+// with a single quote.
+func WriteCSV(w io.Writer, results []Result, opts ReportOptions) error {
+	columns, results, err := opts.prepare(results)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			row[i] = csvCell(result.reportValue(c, opts.ImageBase))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteMarkdown writes results as a Markdown table. Images are linked
+// relative to ImageBase so the report can be moved together with them, and
+// shown as their thumbnail when there is one.
+func WriteMarkdown(w io.Writer, results []Result, opts ReportOptions) error {
+	columns, results, err := opts.prepare(results)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("|")
+	for _, c := range columns {
+		sb.WriteString(" " + reportColumns[c] + " |")
+	}
+	sb.WriteString("\n|")
+	for range columns {
+		sb.WriteString(" --- |")
+	}
+	sb.WriteString("\n")
+
+	for _, result := range results {
+		sb.WriteString("|")
+		for _, c := range columns {
+			value := result.reportValue(c, opts.ImageBase)
+			switch {
+			case value == "":
+			case c == ColumnImage:
+				link := markdownLink(value)
+				preview := link
+				if thumbnail := result.reportValue(ColumnThumbnail, opts.ImageBase); thumbnail != "" {
+					preview = markdownLink(thumbnail)
+				}
+				value = "[![" + escapeMarkdown(result.TargetURL) + "](" + preview + ")](" + link + ")"
+			case c == ColumnThumbnail:
+				value = "![" + escapeMarkdown(result.TargetURL) + "](" + markdownLink(value) + ")"
+			case c == ColumnURL || c == ColumnLandingURL:
+				value = "<" + strings.NewReplacer(">", "%3E", "|", "%7C").Replace(value) + ">"
+			default:
+				value = escapeMarkdown(value)
+			}
+			sb.WriteString(" " + value + " |")
+		}
+		sb.WriteString("\n")
+	}
+
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// prepare validates the columns and returns the results in report order
+func (opts ReportOptions) prepare(results []Result) ([]string, []Result, error) {
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = DefaultReportColumns
+	}
+	for _, c := range columns {
+		if _, ok := reportColumns[c]; !ok {
+			return nil, nil, fmt.Errorf("unknown report column %q", c)
+		}
+	}
+
+	sorted := make([]Result, len(results))
+	copy(sorted, results)
+
+	switch opts.SortBy {
+	case "":
+	case SortByHost:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			hi, hj := sorted[i].reportValue(ColumnHost, ""), sorted[j].reportValue(ColumnHost, "")
+			if hi != hj {
+				return hi < hj
+			}
+			return sorted[i].TargetURL < sorted[j].TargetURL
+		})
+	case SortByStatus:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if sorted[i].StatusCode != sorted[j].StatusCode {
+				return sorted[i].StatusCode < sorted[j].StatusCode
+			}
+			return sorted[i].TargetURL < sorted[j].TargetURL
+		})
+	default:
+		return nil, nil, fmt.Errorf("invalid report sort order %q", opts.SortBy)
+	}
+
+	return columns, sorted, nil
+}
+
+func (result Result) reportValue(column, imageBase string) string {
+	switch column {
+	case ColumnURL:
+		return result.TargetURL
+	case ColumnLandingURL:
+		return result.LandingURL
+	case ColumnHost:
+		if u, err := url.Parse(result.TargetURL); err == nil {
+			return strings.ToLower(u.Hostname())
+		}
+	case ColumnStatus:
+		if result.StatusCode != 0 {
+			return strconv.Itoa(result.StatusCode)
+		}
+	case ColumnTitle:
+		return result.Title
+	case ColumnError:
+		if result.Error != nil {
+			return result.Error.Error()
+		}
+	case ColumnAttempts:
+		if result.Attempts != 0 {
+			return strconv.Itoa(result.Attempts)
+		}
+	case ColumnCapturedAt:
+		if !result.CapturedAt.IsZero() {
+			return result.CapturedAt.UTC().Format(time.RFC3339)
+		}
+	case ColumnDuration:
+		if result.Duration != 0 {
+			return result.Duration.Round(time.Millisecond).String()
+		}
+	case ColumnImage:
+		return relativeImagePath(result.ImageURI, imageBase)
+	case ColumnThumbnail:
+		return relativeImagePath(result.ThumbnailURI, imageBase)
+	}
+	return ""
+}
+
+// relativeImagePath makes local image paths relative to base. URIs of
+// remote or archived images are returned as they are.
+func relativeImagePath(uri, base string) string {
+	if uri == "" || strings.Contains(uri, "://") || strings.Contains(uri, "!/") {
+		return uri
+	}
+
+	abs, err := filepath.Abs(uri)
+	if err != nil {
+		return filepath.ToSlash(uri)
+	}
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return filepath.ToSlash(uri)
+	}
+	rel, err := filepath.Rel(absBase, abs)
+	if err != nil {
+		return filepath.ToSlash(uri)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// csvCell keeps spreadsheets from evaluating s as a formula, as page titles and
+// URLs are chosen by the sites captured
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// markdownLink escapes characters that would end a Markdown link target
+func markdownLink(s string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "|", "%7C").Replace(s)
+}
+
+func escapeMarkdown(s string) string {
+	s = strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;").Replace(s)
+}
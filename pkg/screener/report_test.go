@@ -0,0 +1,95 @@
+package screener
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func reportResults(dir string) []Result {
+	return []Result{
+		{TargetURL: "https://b.example.com", StatusCode: 200, Title: "B | Home", ImageURI: filepath.Join(dir, "shots", "b.png"), ThumbnailURI: filepath.Join(dir, "shots", "b.thumb.png")},
+		{TargetURL: "https://a.example.com", StatusCode: 404},
+		{TargetURL: "https://c.example.com", Error: errors.New("no such host")},
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, reportResults(dir), ReportOptions{
+		Columns:   []string{ColumnHost, ColumnStatus, ColumnError, ColumnImage},
+		SortBy:    SortByHost,
+		ImageBase: dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "host,status,error,image\n" +
+		"a.example.com,404,,\n" +
+		"b.example.com,200,,shots/b.png\n" +
+		"c.example.com,,no such host,\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected CSV\n got: %q\nwant: %q", buf.String(), want)
+	}
+}
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	var results []Result
+	for _, title := range []string{`=HYPERLINK("https://evil.example","x")`, "+1", "-1+2", "@SUM(A1)", "\tTab", "\rReturn", "Plain = title"} {
+		results = append(results, Result{TargetURL: "https://example.com", Title: title})
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, results, ReportOptions{Columns: []string{ColumnTitle}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "title\n" +
+		"\"'=HYPERLINK(\"\"https://evil.example\"\",\"\"x\"\")\"\n" +
+		"'+1\n" +
+		"'-1+2\n" +
+		"'@SUM(A1)\n" +
+		"'\tTab\n" +
+		"\"'\rReturn\"\n" +
+		"Plain = title\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected CSV\n got: %q\nwant: %q", buf.String(), want)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	err := WriteMarkdown(&buf, reportResults(dir), ReportOptions{SortBy: SortByStatus, ImageBase: filepath.Join(dir, "reports")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected header, separator and 3 rows, got %q", buf.String())
+	}
+	if lines[0] != "| URL | Status | Title | Image |" {
+		t.Fatalf("unexpected header %q", lines[0])
+	}
+	// No status sorts first, then by status code
+	if !strings.HasPrefix(lines[2], "| <https://c.example.com> |") || !strings.HasPrefix(lines[3], "| <https://b.example.com> | 200 |") {
+		t.Fatalf("unexpected order %q", lines[2:])
+	}
+	if !strings.Contains(lines[3], `B \| Home`) || !strings.Contains(lines[3], "(../shots/b.thumb.png)](../shots/b.png)") {
+		t.Fatalf("expected escaped title and relative thumbnail linking to the image, got %q", lines[3])
+	}
+}
+
+func TestReportOptionsInvalid(t *testing.T) {
+	if _, err := ParseReportColumns("url,bogus"); err == nil {
+		t.Fatal("expected error for unknown column")
+	}
+	if err := WriteCSV(&bytes.Buffer{}, nil, ReportOptions{SortBy: "size"}); err == nil {
+		t.Fatal("expected error for unknown sort order")
+	}
+}
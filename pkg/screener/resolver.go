@@ -0,0 +1,505 @@
+package screener
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/root4loot/goutils/log"
+	"github.com/root4loot/goutils/sliceutil"
+)
+
+// dohClient is the HTTP client used for DNS-over-HTTPS queries
+var dohClient = &http.Client{}
+
+// dotTLSConfig is the TLS configuration used for DNS-over-TLS queries.
+// A nil value uses the system roots and the resolver host as server name.
+var dotTLSConfig *tls.Config
+
+// resolverSpec describes a configured resolver and the transport used to reach it
+type resolverSpec struct {
+	raw     string // resolver as configured
+	network string // udp, tcp, tcp-tls or https
+	address string // host:port, or the endpoint URL for https
+}
+
+// ValidateResolver reports whether the resolver can be used as a custom resolver.
+// Accepted forms are host, host:port, udp://, tcp://, tls:// and https:// URIs.
+func ValidateResolver(resolver string) error {
+	_, err := parseResolver(resolver)
+	return err
+}
+
+func parseResolver(raw string) (resolverSpec, error) {
+	spec := resolverSpec{raw: raw}
+
+	scheme, rest, found := strings.Cut(raw, "://")
+	if !found {
+		scheme, rest = "udp", raw
+	}
+
+	switch strings.ToLower(scheme) {
+	case "https":
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return spec, fmt.Errorf("invalid DoH resolver %q", raw)
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		spec.network = "https"
+		spec.address = u.String()
+		return spec, nil
+	case "udp", "tcp":
+		spec.network = strings.ToLower(scheme)
+		spec.address = resolverHostPort(rest, "53")
+	case "tls":
+		spec.network = "tcp-tls"
+		spec.address = resolverHostPort(rest, "853")
+	default:
+		return spec, fmt.Errorf("unsupported resolver scheme %q in %q", scheme, raw)
+	}
+
+	if host, _, err := net.SplitHostPort(spec.address); err != nil || host == "" {
+		return spec, fmt.Errorf("invalid resolver address %q", raw)
+	}
+
+	return spec, nil
+}
+
+// resolverHostPort adds the default port to addr unless it already has one
+func resolverHostPort(addr, defaultPort string) string {
+	addr = strings.TrimSuffix(addr, "/")
+	if ip := net.ParseIP(strings.Trim(addr, "[]")); ip != nil {
+		return net.JoinHostPort(ip.String(), defaultPort)
+	}
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(addr, defaultPort)
+}
+
+// exchange sends the query using the resolver's transport and returns the response
+func (r resolverSpec) exchange(m *dns.Msg, timeout time.Duration) (*dns.Msg, error) {
+	if r.network == "https" {
+		return exchangeDoH(r.address, m, timeout)
+	}
+
+	c := &dns.Client{
+		Net:       r.network,
+		Timeout:   timeout,
+		TLSConfig: dotTLSConfig,
+	}
+
+	resp, _, err := c.Exchange(m, r.address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retry truncated UDP answers over TCP
+	if resp.Truncated && r.network == "udp" {
+		c.Net = "tcp"
+		resp, _, err = c.Exchange(m, r.address)
+	}
+
+	return resp, err
+}
+
+// exchangeDoH sends the query as an RFC 8484 wire-format POST request
+func exchangeDoH(endpoint string, m *dns.Msg, timeout time.Duration) (*dns.Msg, error) {
+	q := m.Copy()
+	q.Id = 0
+
+	packed, err := q.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack DNS query: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := dohClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server returned HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &dns.Msg{}
+	if err := r.Unpack(body); err != nil {
+		return nil, fmt.Errorf("failed to unpack DoH response: %w", err)
+	}
+	r.Id = m.Id
+
+	return r, nil
+}
+
+// errNoSuchHost is returned when a resolver authoritatively answers that a host has no addresses
+var errNoSuchHost = errors.New("no such host")
+
+// dnsTimeout is the per-query timeout for custom resolvers
+const dnsTimeout = 5 * time.Second
+
+// DNSRecords holds the answers seen while resolving a target host
+type DNSRecords struct {
+	CNAMEs []string // CNAME chain in resolution order
+	A      []string
+	AAAA   []string
+}
+
+// IPs returns the A and AAAA addresses
+func (d DNSRecords) IPs() []string {
+	return append(append([]string{}, d.A...), d.AAAA...)
+}
+
+// add collects the CNAME chain and addresses from a response for hostname
+func (d *DNSRecords) add(hostname string, resp *dns.Msg) {
+	targets := make(map[string]string)
+	for _, ans := range resp.Answer {
+		switch rr := ans.(type) {
+		case *dns.CNAME:
+			targets[strings.ToLower(rr.Hdr.Name)] = rr.Target
+		case *dns.A:
+			d.addIP(rr.A)
+		case *dns.AAAA:
+			d.addIP(rr.AAAA)
+		}
+	}
+
+	// The chain is the same for the A and AAAA answers
+	if len(d.CNAMEs) > 0 {
+		return
+	}
+
+	name := strings.ToLower(dns.Fqdn(hostname))
+	for range targets {
+		target, ok := targets[name]
+		if !ok {
+			break
+		}
+		d.CNAMEs = append(d.CNAMEs, strings.TrimSuffix(target, "."))
+		name = strings.ToLower(target)
+	}
+}
+
+func (d *DNSRecords) addIP(ip net.IP) {
+	if ip.To4() != nil {
+		if !sliceutil.Contains(d.A, ip.String()) {
+			d.A = append(d.A, ip.String())
+		}
+	} else if !sliceutil.Contains(d.AAAA, ip.String()) {
+		d.AAAA = append(d.AAAA, ip.String())
+	}
+}
+
+// ResolverStats summarizes how a custom resolver performed during a run
+type ResolverStats struct {
+	Resolver   string
+	Queries    int
+	Failures   int
+	AvgLatency time.Duration
+	Benched    int
+}
+
+// resolverPool spreads queries across the custom resolvers and temporarily
+// benches resolvers that keep failing
+type resolverPool struct {
+	mutex       sync.Mutex
+	resolvers   []*pooledResolver
+	next        int
+	maxFailures int
+	benchTime   time.Duration
+}
+
+type pooledResolver struct {
+	spec                resolverSpec
+	queries             int
+	failures            int
+	consecutiveFailures int
+	latency             time.Duration
+	benchCount          int
+	benchedUntil        time.Time
+}
+
+func newResolverPool(resolvers []string, maxFailures int, benchTime time.Duration) *resolverPool {
+	if maxFailures < 1 {
+		maxFailures = 1
+	}
+
+	p := &resolverPool{
+		maxFailures: maxFailures,
+		benchTime:   benchTime,
+	}
+
+	for _, raw := range resolvers {
+		spec, err := parseResolver(raw)
+		if err != nil {
+			log.Warnf("[resolver=%s] %v", raw, err)
+			continue
+		}
+		p.resolvers = append(p.resolvers, &pooledResolver{spec: spec})
+	}
+
+	return p
+}
+
+// pick returns the next resolver in round-robin order that is neither benched nor already tried
+func (p *resolverPool) pick(tried map[*pooledResolver]bool) *pooledResolver {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	n := len(p.resolvers)
+	for i := 0; i < n; i++ {
+		r := p.resolvers[(p.next+i)%n]
+		if tried[r] || now.Before(r.benchedUntil) {
+			continue
+		}
+		p.next = (p.next + i + 1) % n
+		return r
+	}
+
+	return nil
+}
+
+// record updates the resolver's health after a query
+func (p *resolverPool) record(r *pooledResolver, latency time.Duration, failed bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	r.queries++
+	if !failed {
+		r.latency += latency
+		r.consecutiveFailures = 0
+		r.benchCount = 0
+		return
+	}
+
+	r.failures++
+	r.consecutiveFailures++
+
+	// Failures are not reset when benching, so a resolver that is still
+	// broken after its bench expires is benched again on the next failure
+	if r.consecutiveFailures >= p.maxFailures {
+		r.benchCount++
+		bench := p.benchTime << min(r.benchCount-1, 4)
+		r.benchedUntil = time.Now().Add(bench)
+		log.Debugf("[resolver=%s] Benched for %v after %d consecutive failures", r.spec.raw, bench, r.consecutiveFailures)
+	}
+}
+
+// resolve looks up hostname, moving on to the next resolver on transport
+// errors and SERVFAIL/REFUSED answers but trusting NXDOMAIN and empty answers
+func (p *resolverPool) resolve(hostname string) (*pooledResolver, *DNSRecords, error) {
+	tried := make(map[*pooledResolver]bool)
+
+	for {
+		r := p.pick(tried)
+		if r == nil {
+			return nil, nil, fmt.Errorf("no healthy resolvers left for %s", hostname)
+		}
+		tried[r] = true
+
+		contextTag := fmt.Sprintf("[resolver=%s]", r.spec.raw)
+		log.Debugf("%s Trying to resolve %s", contextTag, hostname)
+
+		records, err := p.lookup(r, hostname)
+		if err == nil {
+			log.Debugf("%s Successfully resolved %s to %s", contextTag, hostname, strings.Join(records.IPs(), ", "))
+			return r, records, nil
+		}
+
+		if errors.Is(err, errNoSuchHost) {
+			return r, records, err
+		}
+
+		log.Debugf("%s Failed to resolve %s: %v", contextTag, hostname, err)
+	}
+}
+
+// lookup queries the A and AAAA records of hostname. The records seen so far
+// are returned along with NXDOMAIN so dangling CNAMEs can be reported.
+func (p *resolverPool) lookup(r *pooledResolver, hostname string) (*DNSRecords, error) {
+	records := &DNSRecords{}
+
+	for _, recordType := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		m := &dns.Msg{}
+		m.SetQuestion(dns.Fqdn(hostname), recordType)
+		m.RecursionDesired = true
+
+		start := time.Now()
+		resp, err := r.spec.exchange(m, dnsTimeout)
+		if err != nil {
+			p.record(r, 0, true)
+			return nil, fmt.Errorf("DNS query failed: %w", err)
+		}
+
+		switch resp.Rcode {
+		case dns.RcodeSuccess, dns.RcodeNameError:
+			p.record(r, time.Since(start), false)
+		default:
+			p.record(r, 0, true)
+			return nil, fmt.Errorf("DNS query returned %s", dns.RcodeToString[resp.Rcode])
+		}
+
+		records.add(hostname, resp)
+
+		if resp.Rcode == dns.RcodeNameError {
+			return records, fmt.Errorf("%s: %w (NXDOMAIN)", hostname, errNoSuchHost)
+		}
+	}
+
+	if len(records.IPs()) == 0 {
+		return records, fmt.Errorf("%s: %w (no A or AAAA records)", hostname, errNoSuchHost)
+	}
+
+	return records, nil
+}
+
+// stats returns a snapshot of every resolver's counters
+func (p *resolverPool) stats() []ResolverStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := make([]ResolverStats, 0, len(p.resolvers))
+	for _, r := range p.resolvers {
+		st := ResolverStats{
+			Resolver: r.spec.raw,
+			Queries:  r.queries,
+			Failures: r.failures,
+			Benched:  r.benchCount,
+		}
+		if answered := r.queries - r.failures; answered > 0 {
+			st.AvgLatency = r.latency / time.Duration(answered)
+		}
+		stats = append(stats, st)
+	}
+
+	return stats
+}
+
+// ResolverStats returns per-resolver query statistics for the custom resolvers
+func (s *Screener) ResolverStats() []ResolverStats {
+	if pool := s.pool(); pool != nil {
+		return pool.stats()
+	}
+	return nil
+}
+
+// pool returns the pool for the custom resolvers, creating it on first use
+func (s *Screener) pool() *resolverPool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.resolvers == nil && len(s.CaptureOptions.CustomResolvers) > 0 {
+		s.resolvers = newResolverPool(s.CaptureOptions.CustomResolvers,
+			s.CaptureOptions.ResolverMaxFailures,
+			time.Duration(s.CaptureOptions.ResolverBenchTime)*time.Second)
+	}
+
+	return s.resolvers
+}
+
+// tryResolvers resolves hostname through the custom resolvers. Without custom
+// resolvers the browser resolves hosts itself, and the system resolver is only
+// consulted when the records are needed: to record them, to detect wildcards or
+// to check destinations. Lookup failures are then left for the browser to report.
+func (s *Screener) tryResolvers(hostname string) (string, *DNSRecords, error) {
+	if s.pool() == nil {
+		if !s.CaptureOptions.RecordDNS && s.CaptureOptions.WildcardMode == "" && !s.CaptureOptions.BlockPrivate {
+			return "system", nil, nil
+		}
+		records, err := lookupSystem(hostname)
+		if err != nil {
+			log.Debugf("[resolver=system] %v", err)
+		}
+		return "system", records, nil
+	}
+
+	return s.resolveHost(hostname)
+}
+
+// resolveHost resolves hostname through the custom resolvers, falling back to
+// system DNS when none are configured or none of them could answer
+func (s *Screener) resolveHost(hostname string) (string, *DNSRecords, error) {
+	if pool := s.pool(); pool != nil && net.ParseIP(hostname) == nil {
+		r, records, err := pool.resolve(hostname)
+		if err == nil {
+			return r.spec.raw, records, nil
+		}
+
+		if errors.Is(err, errNoSuchHost) {
+			return "", records, fmt.Errorf("failed to resolve %s using %s: %w", hostname, r.spec.raw, err)
+		}
+
+		log.Debugf("[resolver=system] Falling back to system DNS for %s", hostname)
+	}
+
+	records, err := lookupSystem(hostname)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "system", records, nil
+}
+
+// lookupSystem resolves hostname with the system resolver, which only
+// exposes the canonical name rather than the full CNAME chain
+func lookupSystem(hostname string) (*DNSRecords, error) {
+	records := &DNSRecords{}
+	if ip := net.ParseIP(hostname); ip != nil {
+		records.addIP(ip)
+		return records, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, hostname)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s using custom resolvers and system DNS: %w", hostname, err)
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no IP addresses found for %s", hostname)
+	}
+
+	if cname, err := net.DefaultResolver.LookupCNAME(ctx, hostname); err == nil {
+		cname = strings.TrimSuffix(cname, ".")
+		if !strings.EqualFold(cname, strings.TrimSuffix(hostname, ".")) {
+			records.CNAMEs = []string{cname}
+		}
+	}
+
+	for _, addr := range addrs {
+		records.addIP(addr.IP)
+	}
+	log.Debugf("[resolver=system] Successfully resolved %s to %s using system DNS", hostname, strings.Join(records.IPs(), ", "))
+
+	return records, nil
+}
@@ -0,0 +1,292 @@
+package screener
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseResolver(t *testing.T) {
+	tests := []struct {
+		raw     string
+		network string
+		address string
+		wantErr bool
+	}{
+		{raw: "8.8.8.8", network: "udp", address: "8.8.8.8:53"},
+		{raw: "8.8.8.8:5353", network: "udp", address: "8.8.8.8:5353"},
+		{raw: "2606:4700::1111", network: "udp", address: "[2606:4700::1111]:53"},
+		{raw: "tcp://9.9.9.9", network: "tcp", address: "9.9.9.9:53"},
+		{raw: "tls://1.1.1.1", network: "tcp-tls", address: "1.1.1.1:853"},
+		{raw: "tls://dns.example:8853", network: "tcp-tls", address: "dns.example:8853"},
+		{raw: "https://dns.example/dns-query", network: "https", address: "https://dns.example/dns-query"},
+		{raw: "https://dns.example", network: "https", address: "https://dns.example/dns-query"},
+		{raw: "quic://dns.example", wantErr: true},
+		{raw: "https://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			spec, err := parseResolver(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q", tt.raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error for %q: %v", tt.raw, err)
+			}
+			if spec.network != tt.network || spec.address != tt.address {
+				t.Fatalf("got %s %s, want %s %s", spec.network, spec.address, tt.network, tt.address)
+			}
+		})
+	}
+}
+
+// answerA replies to every A question with 192.0.2.1
+func answerA(w dns.ResponseWriter, r *dns.Msg) {
+	w.WriteMsg(answerAMsg(r))
+}
+
+func answerAMsg(r *dns.Msg) *dns.Msg {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	if r.Question[0].Qtype == dns.TypeA {
+		rr, _ := dns.NewRR(r.Question[0].Name + " 60 IN A 192.0.2.1")
+		m.Answer = append(m.Answer, rr)
+	}
+	return m
+}
+
+func TestResolveOverDoH(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		q := &dns.Msg{}
+		if err := q.Unpack(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		packed, _ := answerAMsg(q).Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	defer srv.Close()
+
+	defer func(c *http.Client) { dohClient = c }(dohClient)
+	dohClient = srv.Client()
+
+	s := NewScreener()
+	s.CaptureOptions.CustomResolvers = []string{srv.URL + "/dns-query"}
+
+	resolver, _, err := s.tryResolvers("doh.example")
+	if err != nil {
+		t.Fatalf("resolve over DoH failed: %v", err)
+	}
+	if resolver != srv.URL+"/dns-query" {
+		t.Fatalf("expected DoH resolver to answer, got %q", resolver)
+	}
+}
+
+func TestResolveOverDoT(t *testing.T) {
+	// Borrow the httptest certificate, which is valid for 127.0.0.1
+	certSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer certSrv.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certSrv.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	startDNSServer(t, &dns.Server{Listener: ln, Net: "tcp-tls", Handler: dns.HandlerFunc(answerA)})
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certSrv.Certificate())
+	defer func(c *tls.Config) { dotTLSConfig = c }(dotTLSConfig)
+	dotTLSConfig = &tls.Config{RootCAs: pool}
+
+	s := NewScreener()
+	s.CaptureOptions.CustomResolvers = []string{"tls://" + ln.Addr().String()}
+
+	if _, _, err := s.tryResolvers("dot.example"); err != nil {
+		t.Fatalf("resolve over DoT failed: %v", err)
+	}
+}
+
+func TestResolveOverTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	startDNSServer(t, &dns.Server{Listener: ln, Net: "tcp", Handler: dns.HandlerFunc(answerA)})
+
+	s := NewScreener()
+	resolver := "tcp://" + ln.Addr().String()
+	s.CaptureOptions.CustomResolvers = []string{resolver}
+
+	got, _, err := s.tryResolvers("tcp.example")
+	if err != nil {
+		t.Fatalf("resolve over TCP failed: %v", err)
+	}
+	if got != resolver {
+		t.Fatalf("expected %q to answer, got %q", resolver, got)
+	}
+}
+
+func startDNSServer(t *testing.T, srv *dns.Server) {
+	t.Helper()
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+}
+
+func TestResolverPoolBenchesFailingResolver(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	startDNSServer(t, &dns.Server{Listener: ln, Net: "tcp", Handler: dns.HandlerFunc(answerA)})
+
+	dead := "udp://127.0.0.1:1"
+	s := NewScreener()
+	s.CaptureOptions.CustomResolvers = []string{dead, "tcp://" + ln.Addr().String()}
+
+	for i := 0; i < 10; i++ {
+		if _, _, err := s.tryResolvers(fmt.Sprintf("host%d.example", i)); err != nil {
+			t.Fatalf("resolve failed: %v", err)
+		}
+	}
+
+	stats := s.ResolverStats()
+	if stats[0].Resolver != dead {
+		t.Fatalf("unexpected resolver order: %+v", stats)
+	}
+	if stats[0].Failures != s.CaptureOptions.ResolverMaxFailures || stats[0].Benched != 1 {
+		t.Fatalf("expected dead resolver to be benched after %d failures, got %+v", s.CaptureOptions.ResolverMaxFailures, stats[0])
+	}
+	if stats[1].Failures != 0 || stats[1].Queries < 10 {
+		t.Fatalf("expected healthy resolver to answer every host, got %+v", stats[1])
+	}
+}
+
+func TestResolverPoolRcodeHandling(t *testing.T) {
+	servfail := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := &dns.Msg{}
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+	})
+	nxdomain := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := &dns.Msg{}
+		m.SetRcode(r, dns.RcodeNameError)
+		w.WriteMsg(m)
+	})
+	healthy := startUDPServer(t, answerA)
+
+	// SERVFAIL moves on to the next resolver
+	pool := newResolverPool([]string{servfail, healthy}, 3, time.Minute)
+	r, records, err := pool.resolve("servfail.example")
+	if err != nil || r.spec.raw != healthy || len(records.A) != 1 || records.A[0] != "192.0.2.1" {
+		t.Fatalf("expected SERVFAIL to be retried on %s, got %v %v %v", healthy, r, records, err)
+	}
+
+	// NXDOMAIN is trusted and not retried
+	pool = newResolverPool([]string{nxdomain, healthy}, 3, time.Minute)
+	_, _, err = pool.resolve("nxdomain.example")
+	if !errors.Is(err, errNoSuchHost) {
+		t.Fatalf("expected NXDOMAIN to be trusted, got %v", err)
+	}
+	if stats := pool.stats(); stats[1].Queries != 0 {
+		t.Fatalf("expected no queries after NXDOMAIN, got %+v", stats[1])
+	}
+}
+
+func startUDPServer(t *testing.T, handler dns.HandlerFunc) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	startDNSServer(t, &dns.Server{PacketConn: pc, Net: "udp", Handler: handler})
+	return pc.LocalAddr().String()
+}
+
+func TestResolveRecordsCNAMEChain(t *testing.T) {
+	resolver := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := &dns.Msg{}
+		m.SetReply(r)
+		q := r.Question[0]
+
+		var chain []string
+		switch q.Name {
+		case "www.app.example.":
+			chain = []string{"www.app.example. 60 IN CNAME app.cdn.example.", "app.cdn.example. 60 IN CNAME edge.cdn.example."}
+			if q.Qtype == dns.TypeA {
+				chain = append(chain, "edge.cdn.example. 60 IN A 192.0.2.5")
+			} else {
+				chain = append(chain, "edge.cdn.example. 60 IN AAAA 2001:db8::5")
+			}
+		case "old.app.example.":
+			chain = []string{"old.app.example. 60 IN CNAME gone.thirdparty.example."}
+			m.Rcode = dns.RcodeNameError
+		}
+
+		for _, s := range chain {
+			rr, _ := dns.NewRR(s)
+			m.Answer = append(m.Answer, rr)
+		}
+		w.WriteMsg(m)
+	})
+
+	s := NewScreener()
+	s.CaptureOptions.CustomResolvers = []string{resolver}
+
+	_, records, err := s.tryResolvers("www.app.example")
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if strings.Join(records.CNAMEs, ",") != "app.cdn.example,edge.cdn.example" {
+		t.Errorf("unexpected CNAME chain %v", records.CNAMEs)
+	}
+	if len(records.A) != 1 || records.A[0] != "192.0.2.5" || len(records.AAAA) != 1 || records.AAAA[0] != "2001:db8::5" {
+		t.Errorf("unexpected addresses A=%v AAAA=%v", records.A, records.AAAA)
+	}
+
+	// A dangling CNAME is still reported alongside NXDOMAIN
+	_, records, err = s.tryResolvers("old.app.example")
+	if !errors.Is(err, errNoSuchHost) {
+		t.Fatalf("expected NXDOMAIN, got %v", err)
+	}
+	if records == nil || len(records.CNAMEs) != 1 || records.CNAMEs[0] != "gone.thirdparty.example" {
+		t.Errorf("expected dangling CNAME to be recorded, got %+v", records)
+	}
+}
+
+func TestTryResolversSkipsSystemLookup(t *testing.T) {
+	s := NewScreener()
+
+	// The browser resolves hosts itself unless the records are wanted
+	resolver, records, err := s.tryResolvers("localhost")
+	if err != nil || resolver != "system" || records != nil {
+		t.Fatalf("expected no lookup, got %s %v %v", resolver, records, err)
+	}
+
+	s.CaptureOptions.RecordDNS = true
+	if _, records, _ := s.tryResolvers("127.0.0.1"); records == nil || len(records.IPs()) != 1 {
+		t.Fatalf("expected records when recording DNS, got %v", records)
+	}
+}
@@ -0,0 +1,187 @@
+// Package resultsdb records screener results in a SQLite database. It uses
+// the cgo SQLite driver, so it is kept out of package screener, which builds
+// without cgo.
+package resultsdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glaslos/ssdeep"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/root4loot/screener/pkg/screener"
+)
+
+// schema is applied when a database is opened. Times are stored as
+// RFC 3339 UTC text so they compare correctly as strings.
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at  TEXT NOT NULL,
+	finished_at TEXT,
+	args        TEXT
+);
+CREATE TABLE IF NOT EXISTS captures (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id        INTEGER NOT NULL REFERENCES runs(id),
+	target_url    TEXT NOT NULL,
+	host          TEXT NOT NULL,
+	landing_url   TEXT,
+	outcome       TEXT,
+	status_code   INTEGER,
+	title         TEXT,
+	headers       TEXT,
+	error         TEXT,
+	attempts      INTEGER,
+	started_at    TEXT,
+	captured_at   TEXT,
+	duration_ms   INTEGER,
+	sha256        TEXT,
+	ssdeep        TEXT,
+	image_uri     TEXT,
+	thumbnail_uri TEXT,
+	image         BLOB
+);
+CREATE INDEX IF NOT EXISTS captures_run_id ON captures(run_id);
+CREATE INDEX IF NOT EXISTS captures_host ON captures(host);
+CREATE INDEX IF NOT EXISTS captures_target_url ON captures(target_url);
+`
+
+// DB records captures in a SQLite database. Each time it is opened a
+// new run is started, and every capture recorded is tagged with its ID so
+// results accumulate across runs. It is safe for concurrent use.
+type DB struct {
+	// StoreImages stores the image itself in the database, not just its URI
+	StoreImages bool
+	mutex       sync.Mutex
+	db          *sql.DB
+	runID       int64
+}
+
+// Open opens or creates the database at path and starts a new run.
+// args describes the run, typically the command line.
+func Open(path, args string) (*DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating schema in %s: %w", path, err)
+	}
+
+	res, err := db.Exec("INSERT INTO runs (started_at, args) VALUES (?, ?)", formatDBTime(time.Now()), args)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error starting run in %s: %w", path, err)
+	}
+
+	runID, err := res.LastInsertId()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{db: db, runID: runID}, nil
+}
+
+// RunID returns the ID of the current run
+func (r *DB) RunID() int64 {
+	return r.runID
+}
+
+// Record stores result with its outcome
+func (r *DB) Record(result screener.Result, outcome string) error {
+	if r == nil {
+		return nil
+	}
+
+	var host string
+	if u, err := url.Parse(result.TargetURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+
+	var headers, errText, sum, fuzzy sql.NullString
+	if len(result.Headers) > 0 {
+		b, err := json.Marshal(result.Headers)
+		if err != nil {
+			return err
+		}
+		headers = sql.NullString{String: string(b), Valid: true}
+	}
+	if result.Error != nil {
+		errText = sql.NullString{String: result.Error.Error(), Valid: true}
+	}
+
+	sum = nullString(result.RawSHA256)
+
+	var image []byte
+	if len(result.Image) > 0 {
+		if h, err := ssdeep.FuzzyBytes(result.Image); err == nil {
+			fuzzy = sql.NullString{String: h, Valid: true}
+		}
+		if r.StoreImages {
+			image = result.Image
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, err := r.db.Exec(`INSERT INTO captures (
+		run_id, target_url, host, landing_url, outcome, status_code, title, headers, error,
+		attempts, started_at, captured_at, duration_ms, sha256, ssdeep, image_uri, thumbnail_uri, image
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.runID, result.TargetURL, host, nullString(result.LandingURL), nullString(outcome),
+		nullInt(result.StatusCode), nullString(result.Title), headers, errText,
+		nullInt(result.Attempts), nullTime(result.StartedAt), nullTime(result.CapturedAt),
+		result.Duration.Milliseconds(), sum, fuzzy, nullString(result.ImageURI), nullString(result.ThumbnailURI), image)
+	if err != nil {
+		return fmt.Errorf("error recording %s: %w", result.TargetURL, err)
+	}
+
+	return nil
+}
+
+// Close marks the run as finished and closes the database
+func (r *DB) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, err := r.db.Exec("UPDATE runs SET finished_at = ? WHERE id = ?", formatDBTime(time.Now()), r.runID)
+	if closeErr := r.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func formatDBTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n != 0}
+}
+
+func nullTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatDBTime(t), Valid: true}
+}
@@ -0,0 +1,92 @@
+package resultsdb
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/root4loot/screener/pkg/screener"
+)
+
+func TestResultsDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.sqlite")
+
+	first, err := Open(path, "screener -l targets.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.StoreImages = true
+	err = first.Record(screener.Result{
+		TargetURL:    "https://Example.com/",
+		LandingURL:   "https://example.com/home",
+		StatusCode:   200,
+		Title:        "Example",
+		Headers:      map[string]string{"Server": "nginx"},
+		Image:        screener.Image("png"),
+		RawSHA256:    "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Attempts:     1,
+		StartedAt:    time.Now(),
+		CapturedAt:   time.Now(),
+		Duration:     1500 * time.Millisecond,
+		ImageURI:     "screenshots/https_example.com.png",
+		ThumbnailURI: "screenshots/https_example.com.thumb.png",
+	}, "saved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Record(screener.Result{TargetURL: "https://down.example.com", Error: errors.New("no such host")}, "dns"); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := Open(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RunID() != first.RunID()+1 {
+		t.Fatalf("expected a new run, got %d after %d", second.RunID(), first.RunID())
+	}
+	if err := second.Record(screener.Result{TargetURL: "https://example.com/", StatusCode: 503}, "status"); err != nil {
+		t.Fatal(err)
+	}
+	second.Close()
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var host, title, headers, sum, thumbnail string
+	var durationMS int64
+	var image []byte
+	err = db.QueryRow(`SELECT host, title, headers, sha256, duration_ms, image, thumbnail_uri FROM captures WHERE run_id = ? AND outcome = 'saved'`, first.RunID()).
+		Scan(&host, &title, &headers, &sum, &durationMS, &image, &thumbnail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "example.com" || title != "Example" || headers != `{"Server":"nginx"}` || durationMS != 1500 || string(image) != "png" || thumbnail != "screenshots/https_example.com.thumb.png" {
+		t.Fatalf("unexpected row: %s %s %s %d %q %s", host, title, headers, durationMS, image, thumbnail)
+	}
+	// The hash of the image as captured, not of the annotated image stored
+	if sum != "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08" {
+		t.Fatalf("unexpected sha256 %q", sum)
+	}
+
+	// Hosts whose status changed between runs
+	var changed string
+	err = db.QueryRow(`SELECT a.host FROM captures a JOIN captures b ON a.host = b.host
+		WHERE a.run_id = ? AND b.run_id = ? AND a.status_code != b.status_code`, first.RunID(), second.RunID()).Scan(&changed)
+	if err != nil || changed != "example.com" {
+		t.Fatalf("expected example.com to have changed status, got %q: %v", changed, err)
+	}
+
+	var finished sql.NullString
+	if err := db.QueryRow(`SELECT finished_at FROM runs WHERE id = ?`, first.RunID()).Scan(&finished); err != nil || !finished.Valid {
+		t.Fatalf("expected run to be finished: %v", err)
+	}
+}
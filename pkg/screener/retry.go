@@ -0,0 +1,163 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/root4loot/goutils/log"
+)
+
+// Retryable failure categories
+const (
+	RetryConnReset    = "reset"
+	RetryTimeout      = "timeout"
+	RetryServerError  = "5xx"
+	RetryBrowserCrash = "crash"
+)
+
+// ErrBrowserCrash is returned when the browser or page crashes mid-capture
+var ErrBrowserCrash = errors.New("browser crashed")
+
+// RetryPolicy controls how transient failures are retried
+type RetryPolicy struct {
+	MaxAttempts    int           // total attempts including the first; 1 disables retries
+	InitialBackoff time.Duration // wait before the second attempt, doubled for every further attempt
+	MaxBackoff     time.Duration // cap on the wait between attempts
+	RetryOn        []string      // retryable categories
+}
+
+// DefaultRetryPolicy makes a single attempt. Raising MaxAttempts retries
+// every category.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    1,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		RetryOn:        []string{RetryConnReset, RetryTimeout, RetryServerError, RetryBrowserCrash},
+	}
+}
+
+// ParseRetryOn parses a comma separated list of retryable categories
+func ParseRetryOn(spec string) ([]string, error) {
+	var categories []string
+	for _, c := range strings.Split(spec, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		switch c {
+		case "":
+			continue
+		case RetryConnReset, RetryTimeout, RetryServerError, RetryBrowserCrash:
+			categories = append(categories, c)
+		default:
+			return nil, fmt.Errorf("unknown retry category %q", c)
+		}
+	}
+	return categories, nil
+}
+
+// backoff returns the wait before the given attempt (2 or later), with
+// jitter spreading it between half and the full exponential delay
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 2; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+func (p RetryPolicy) retries(category string) bool {
+	for _, c := range p.RetryOn {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// retryCategory returns the retryable category of a failed attempt, or ""
+func retryCategory(result *Result, err error) string {
+	if err == nil {
+		if result != nil && result.StatusCode >= 500 && result.StatusCode <= 599 {
+			return RetryServerError
+		}
+		return ""
+	}
+
+	if errors.Is(err, ErrOutOfScope) || errors.Is(err, ErrForbiddenDestination) || errors.Is(err, ErrWildcard) {
+		return ""
+	}
+
+	// Resolution failures, including DNS timeouts, are not retried: the
+	// resolvers already retry and fall back to each other
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) || errors.Is(err, errNoSuchHost) {
+		return ""
+	}
+
+	if errors.Is(err, ErrBrowserCrash) {
+		return RetryBrowserCrash
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) {
+		return RetryConnReset
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return RetryTimeout
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "ERR_CONNECTION_RESET"), strings.Contains(msg, "ERR_CONNECTION_CLOSED"),
+		strings.Contains(msg, "ERR_EMPTY_RESPONSE"), strings.Contains(msg, "connection reset"):
+		return RetryConnReset
+	case strings.Contains(msg, "ERR_TIMED_OUT"), strings.Contains(msg, "ERR_CONNECTION_TIMED_OUT"),
+		strings.Contains(msg, "timed out"), strings.Contains(msg, "timeout"):
+		return RetryTimeout
+	case strings.Contains(msg, "Target crashed"), strings.Contains(msg, "websocket: close"),
+		strings.Contains(msg, "use of closed network connection"):
+		return RetryBrowserCrash
+	}
+
+	return ""
+}
+
+// captureWithRetry runs capture attempts according to the retry policy until
+// ctx is done. The final flag tells an attempt that it will not be retried.
+func (s *Screener) captureWithRetry(ctx context.Context, parsedURL *url.URL, capture func(context.Context, *url.URL, bool) (*Result, error)) (*Result, error) {
+	policy := s.CaptureOptions.Retry
+	maxAttempts := max(policy.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		result, err := capture(ctx, parsedURL, attempt == maxAttempts)
+		if result != nil {
+			result.Attempts = attempt
+		}
+
+		category := retryCategory(result, err)
+		if attempt == maxAttempts || category == "" || !policy.retries(category) || ctx.Err() != nil {
+			return result, err
+		}
+
+		delay := policy.backoff(attempt + 1)
+		log.Debugf("[capture=%s] Attempt %d/%d failed (%s), retrying in %v", parsedURL, attempt, maxAttempts, category, delay.Round(time.Millisecond))
+		if err := sleepContext(ctx, delay); err != nil {
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRetryCategory(t *testing.T) {
+	tests := []struct {
+		result *Result
+		err    error
+		want   string
+	}{
+		{&Result{StatusCode: 503}, nil, RetryServerError},
+		{&Result{StatusCode: 404}, nil, ""},
+		{nil, errors.New("navigation failed: net::ERR_CONNECTION_RESET"), RetryConnReset},
+		{nil, fmt.Errorf("load: %w", context.DeadlineExceeded), RetryTimeout},
+		{nil, fmt.Errorf("%w: websocket closed", ErrBrowserCrash), RetryBrowserCrash},
+		{nil, &ScopeError{URL: "https://example.com/"}, ""},
+		{nil, errors.New("net::ERR_NAME_NOT_RESOLVED"), ""},
+		{nil, fmt.Errorf("failed to resolve: %w", &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}), ""},
+		{nil, fmt.Errorf("failed to resolve: %w", &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}), ""},
+		{nil, fmt.Errorf("failed to resolve using 192.0.2.53: %w", errNoSuchHost), ""},
+		{nil, &net.OpError{Op: "dial", Err: timeoutError{}}, RetryTimeout},
+	}
+
+	for _, tt := range tests {
+		if got := retryCategory(tt.result, tt.err); got != tt.want {
+			t.Errorf("retryCategory(%v, %v) = %q, want %q", tt.result, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+
+	for attempt, want := range map[int]time.Duration{2: 100, 3: 200, 4: 300, 10: 300} {
+		want *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(attempt); d < want/2 || d > want {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempt, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestCaptureWithRetry(t *testing.T) {
+	s := NewScreener()
+	s.CaptureOptions.Retry = RetryPolicy{MaxAttempts: 3, RetryOn: []string{RetryServerError, RetryConnReset}}
+	u, _ := url.Parse("https://example.com/")
+
+	var finals []bool
+	result, err := s.captureWithRetry(context.Background(), u, func(_ context.Context, _ *url.URL, final bool) (*Result, error) {
+		finals = append(finals, final)
+		if len(finals) < 3 {
+			return &Result{StatusCode: 502}, nil
+		}
+		return &Result{StatusCode: 200}, nil
+	})
+	if err != nil || result.StatusCode != 200 || result.Attempts != 3 {
+		t.Fatalf("expected success on third attempt, got %+v, %v", result, err)
+	}
+	if fmt.Sprint(finals) != "[false false true]" {
+		t.Fatalf("unexpected final flags %v", finals)
+	}
+
+	// Categories that are not enabled are not retried
+	calls := 0
+	_, err = s.captureWithRetry(context.Background(), u, func(context.Context, *url.URL, bool) (*Result, error) {
+		calls++
+		return nil, context.DeadlineExceeded
+	})
+	if calls != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a single attempt for timeouts, got %d (%v)", calls, err)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "deadline exceeded" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,165 @@
+package screener
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ErrOutOfScope is wrapped by ScopeError
+var ErrOutOfScope = errors.New("out of scope")
+
+// ScopeError is returned when a target or one of its redirects is out of scope
+type ScopeError struct {
+	URL string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("%s is out of scope", e.URL)
+}
+
+func (e *ScopeError) Unwrap() error {
+	return ErrOutOfScope
+}
+
+// Scope decides which URLs may be captured. Rules are one per line:
+//
+//	example.com           exact host
+//	*.example.com         any subdomain of example.com
+//	10.0.0.0/8            IP addresses in a CIDR
+//	re:^https://[^/]+/app regular expression matched against the full URL
+//	!staging.example.com  exclude, using any of the forms above
+//
+// Excludes take precedence. Without include rules everything not excluded is in scope.
+type Scope struct {
+	includes []scopeRule
+	excludes []scopeRule
+}
+
+type scopeRule struct {
+	host     string
+	wildcard bool
+	prefix   netip.Prefix
+	re       *regexp.Regexp
+}
+
+// LoadScope reads scope rules from a file
+func LoadScope(path string) (*Scope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseScope(f)
+}
+
+// ParseScope reads scope rules, ignoring blank lines and # comments
+func ParseScope(r io.Reader) (*Scope, error) {
+	scope := &Scope{}
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		exclude := strings.HasPrefix(text, "!")
+		rule, err := parseScopeRule(strings.TrimSpace(strings.TrimPrefix(text, "!")))
+		if err != nil {
+			return nil, fmt.Errorf("scope line %d: %w", line, err)
+		}
+
+		if exclude {
+			scope.excludes = append(scope.excludes, rule)
+		} else {
+			scope.includes = append(scope.includes, rule)
+		}
+	}
+
+	return scope, scanner.Err()
+}
+
+func parseScopeRule(text string) (scopeRule, error) {
+	if expr, ok := strings.CutPrefix(text, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return scopeRule{}, fmt.Errorf("invalid regex %q: %w", expr, err)
+		}
+		return scopeRule{re: re}, nil
+	}
+
+	if prefix, err := netip.ParsePrefix(text); err == nil {
+		return scopeRule{prefix: prefix.Masked()}, nil
+	}
+
+	if addr, err := netip.ParseAddr(text); err == nil {
+		return scopeRule{prefix: netip.PrefixFrom(addr, addr.BitLen())}, nil
+	}
+
+	// Accept URLs and host:port by keeping only the host
+	host := text
+	if u, err := url.Parse(text); err == nil && u.Host != "" {
+		host = u.Hostname()
+	} else if h, _, found := strings.Cut(host, ":"); found {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if rest, ok := strings.CutPrefix(host, "*."); ok {
+		return scopeRule{host: rest, wildcard: true}, nil
+	}
+	if host == "" || strings.ContainsAny(host, "*/ ") {
+		return scopeRule{}, fmt.Errorf("invalid scope rule %q", text)
+	}
+
+	return scopeRule{host: host}, nil
+}
+
+func (r scopeRule) matches(u *url.URL) bool {
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+
+	switch {
+	case r.re != nil:
+		return r.re.MatchString(u.String())
+	case r.prefix.IsValid():
+		addr, err := netip.ParseAddr(host)
+		return err == nil && r.prefix.Contains(addr.Unmap())
+	case r.wildcard:
+		return strings.HasSuffix(host, "."+r.host)
+	default:
+		return host == r.host
+	}
+}
+
+// InScope reports whether u may be captured
+func (s *Scope) InScope(u *url.URL) bool {
+	if s == nil {
+		return true
+	}
+
+	for _, rule := range s.excludes {
+		if rule.matches(u) {
+			return false
+		}
+	}
+
+	if len(s.includes) == 0 {
+		return true
+	}
+
+	for _, rule := range s.includes {
+		if rule.matches(u) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+package screener
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	rules := `# engagement scope
+example.com
+*.example.com
+https://app.example.org:8443/login
+10.0.0.0/8
+192.0.2.1
+re:^https://partner\.example\.net/app/
+
+!admin.example.com
+!10.0.0.99
+`
+
+	scope, err := ParseScope(strings.NewReader(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/", true},
+		{"https://EXAMPLE.com./path", true},
+		{"https://www.example.com/", true},
+		{"https://a.b.example.com/", true},
+		{"https://admin.example.com/", false},
+		{"https://notexample.com/", false},
+		{"https://app.example.org/", true},
+		{"https://www.example.org/", false},
+		{"http://10.1.2.3:8080/", true},
+		{"http://10.0.0.99/", false},
+		{"http://192.0.2.1/", true},
+		{"http://192.0.2.2/", false},
+		{"https://partner.example.net/app/home", true},
+		{"https://partner.example.net/other", false},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := scope.InScope(u); got != tt.want {
+			t.Errorf("InScope(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestScopeExcludesOnly(t *testing.T) {
+	scope, err := ParseScope(strings.NewReader("!*.internal.example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, _ := url.Parse("https://www.example.com/")
+	out, _ := url.Parse("https://db.internal.example.com/")
+	if !scope.InScope(in) || scope.InScope(out) {
+		t.Fatal("expected everything except excluded hosts to be in scope")
+	}
+
+	var none *Scope
+	if !none.InScope(out) {
+		t.Fatal("expected nil scope to allow everything")
+	}
+}
+
+func TestParseScopeInvalid(t *testing.T) {
+	for _, rule := range []string{"re:([", "foo/*"} {
+		if _, err := ParseScope(strings.NewReader(rule)); err == nil {
+			t.Errorf("expected error for rule %q", rule)
+		}
+	}
+
+	err := error(&ScopeError{URL: "https://example.com/"})
+	if !errors.Is(err, ErrOutOfScope) {
+		t.Fatal("expected ScopeError to wrap ErrOutOfScope")
+	}
+}
@@ -1,47 +1,57 @@
 package screener
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"fmt"
-	"image/color"
-	"image/png"
-	"net"
 	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/fogleman/gg"
 	"github.com/glaslos/ssdeep"
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
 	"github.com/go-rod/rod/lib/proto"
-	"github.com/golang/freetype/truetype"
-	"github.com/miekg/dns"
 	"github.com/root4loot/goutils/log"
 	"github.com/root4loot/goutils/sliceutil"
 	"github.com/root4loot/goutils/urlutil"
-	"golang.org/x/image/font"
 )
 
 type Screener struct {
 	Debug          bool
 	CaptureOptions captureOptions
 	visited        map[string]bool
+	resolvers      *resolverPool
+	wildcards      map[string]*wildcardEntry
+	limiter        *RateLimiter
 	mutex          sync.Mutex
 }
 
 type Result struct {
-	TargetURL  string
-	LandingURL string
-	Image      Image
-	StatusCode int
-	Error      error
-	Resolver   string
+	TargetURL    string
+	LandingURL   string
+	Image        Image
+	StatusCode   int
+	Error        error
+	Resolver     string
+	DNS          DNSRecords
+	Wildcard     bool
+	Metadata     map[string]string
+	Attempts     int
+	CapturedAt   time.Time
+	Viewport     string
+	Title        string
+	Headers      map[string]string // response headers of the first response
+	StartedAt    time.Time
+	Duration     time.Duration // time taken by the last attempt
+	ImageURI     string        // where the image was stored
+	Thumbnail    Image
+	ThumbnailURI string
+	RawSHA256    string // SHA-256 of the image as captured, before annotation
 }
 
 type Image []byte
@@ -55,11 +65,23 @@ type captureOptions struct {
 	UserAgent                string
 	DelayBeforeCapture       int
 	DelayBetweenCapture      int
+	RateLimit                float64
+	HostRateLimit            float64
+	HostConcurrency          int
 	IgnoreRedirects          bool
 	IgnoreStatusCodes        []int
 	CaptureFull              bool
 	ScreenshotErrors         bool
 	CustomResolvers          []string
+	RecordDNS                bool // look up DNS records even without custom resolvers
+	ResolverMaxFailures      int
+	ResolverBenchTime        int
+	WildcardMode             string
+	Retry                    RetryPolicy
+	Scope                    *Scope
+	ScopeSubresources        bool
+	State                    *StateFile // persists visited URLs across runs when set
+	BlockPrivate             bool
 	Proxy                    string
 }
 
@@ -77,6 +99,9 @@ func NewOptions() captureOptions {
 		CaptureFull:              false,
 		UserAgent:                "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
 		IgnoreStatusCodes:        []int{204, 301, 302, 304, 401, 407},
+		Retry:                    DefaultRetryPolicy(),
+		ResolverMaxFailures:      3,
+		ResolverBenchTime:        30,
 		Proxy:                    "",
 	}
 }
@@ -112,112 +137,84 @@ func Init() {
 	log.SetLevel(log.InfoLevel)
 }
 
-func (s *Screener) tryResolvers(hostname string) (string, error) {
-	if len(s.CaptureOptions.CustomResolvers) == 0 {
-		return "system", nil
-	}
-
-	// Try custom resolvers first using direct DNS queries
-	for _, resolver := range s.CaptureOptions.CustomResolvers {
-		contextTag := fmt.Sprintf("[resolver=%s]", resolver)
-		log.Debugf("%s Trying to resolve %s", contextTag, hostname)
+// CaptureScreenshot takes a screenshot of the provided URL and returns the result.
+// When the host fails to resolve, the returned result carries the DNS records
+// seen so far (such as a dangling CNAME) alongside the error. Targets that are,
+// or redirect, out of scope are returned with a ScopeError and no image. With
+// BlockPrivate set, private and reserved destinations yield a DestinationError.
+// Transient failures are retried according to CaptureOptions.Retry and the
+// number of attempts made is recorded in the result.
+func (s *Screener) CaptureScreenshot(parsedURL *url.URL) (*Result, error) {
+	return s.CaptureScreenshotContext(context.Background(), parsedURL)
+}
 
-		// Use miekg/dns to make direct DNS queries
-		resolverAddress := resolver
-		if !strings.Contains(resolver, ":") {
-			resolverAddress = net.JoinHostPort(resolver, "53")
-		}
+// CaptureScreenshotContext is like CaptureScreenshot but stops waiting and
+// closes the browser once ctx is done, returning the context's error.
+func (s *Screener) CaptureScreenshotContext(ctx context.Context, parsedURL *url.URL) (*Result, error) {
+	return s.captureWithRetry(ctx, parsedURL, s.captureOnce)
+}
 
-		// Try A record first
-		if ip, err := s.queryDNSRecord(resolverAddress, hostname, dns.TypeA); err == nil {
-			log.Debugf("%s Successfully resolved %s to %s (A record)", contextTag, hostname, ip)
-			return resolver, nil
+// captureOnce makes a single capture attempt. Browser panics are recovered
+// and reported as ErrBrowserCrash. Unless this is the final attempt, failed
+// navigations that may succeed on retry are returned as errors rather than
+// captured as they are.
+func (s *Screener) captureOnce(parent context.Context, parsedURL *url.URL, final bool) (result *Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("%w: %v", ErrBrowserCrash, r)
 		}
-
-		// Try AAAA record for IPv6
-		if ip, err := s.queryDNSRecord(resolverAddress, hostname, dns.TypeAAAA); err == nil {
-			log.Debugf("%s Successfully resolved %s to %s (AAAA record)", contextTag, hostname, ip)
-			return resolver, nil
+		if result != nil {
+			result.Duration = time.Since(result.StartedAt)
 		}
+	}()
 
-		log.Debugf("%s Failed to resolve %s", contextTag, hostname)
-	}
+	result = &Result{StartedAt: time.Now()}
 
-	// fallback to system dns
-	log.Debugf("[resolver=system] Falling back to system DNS for %s", hostname)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	captureURL := parsedURL.String()
+	result.TargetURL = captureURL
+	contextTag := fmt.Sprintf("[capture=%s]", captureURL)
 
-	ips, err := net.DefaultResolver.LookupIPAddr(ctx, hostname)
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve %s using custom resolvers and system DNS: %v", hostname, err)
+	if parsedURL.Scheme == "" {
+		return nil, fmt.Errorf("no URL scheme provided; expected http or https")
 	}
 
-	if len(ips) > 0 {
-		log.Debugf("[resolver=system] Successfully resolved %s to %s using system DNS", hostname, ips[0].IP.String())
-		return "system", nil
+	if s.CaptureOptions.BlockPrivate && s.CaptureOptions.Proxy != "" {
+		return nil, fmt.Errorf("blocking private destinations cannot be combined with a proxy")
 	}
 
-	return "", fmt.Errorf("no IP addresses found for %s", hostname)
-}
-
-func (s *Screener) queryDNSRecord(resolverAddress, hostname string, recordType uint16) (string, error) {
-	c := &dns.Client{
-		Timeout: 5 * time.Second,
+	if !s.CaptureOptions.Scope.InScope(parsedURL) {
+		err := &ScopeError{URL: captureURL}
+		log.Debugf("%s %v", contextTag, err)
+		result.Error = err
+		return result, err
 	}
 
-	m := &dns.Msg{}
-	m.SetQuestion(dns.Fqdn(hostname), recordType)
-	m.RecursionDesired = true
-
-	r, _, err := c.Exchange(m, resolverAddress)
-	if err != nil {
-		return "", fmt.Errorf("DNS query failed: %v", err)
+	resolver, records, err := s.tryResolvers(parsedURL.Hostname())
+	result.Resolver = resolver
+	if records != nil {
+		result.DNS = *records
 	}
-
-	if r.Rcode != dns.RcodeSuccess {
-		return "", fmt.Errorf("DNS query returned error code: %v", r.Rcode)
+	if err != nil {
+		log.Warnf("%s %v", contextTag, err)
+		result.Error = err
+		return result, err
 	}
 
-	// Parse the response based on record type
-	for _, ans := range r.Answer {
-		switch recordType {
-		case dns.TypeA:
-			if a, ok := ans.(*dns.A); ok {
-				return a.A.String(), nil
-			}
-		case dns.TypeAAAA:
-			if aaaa, ok := ans.(*dns.AAAA); ok {
-				return aaaa.AAAA.String(), nil
-			}
+	if s.CaptureOptions.WildcardMode != "" {
+		wildcard := s.isWildcard(parsedURL.Hostname(), result.DNS.IPs())
+		if wildcard && s.CaptureOptions.WildcardMode == WildcardSkip {
+			log.Debugf("%s Skipping %s as it resolves to a wildcard DNS record", contextTag, captureURL)
+			return nil, ErrWildcard
 		}
+		result.Wildcard = wildcard
 	}
 
-	return "", fmt.Errorf("no %s records found for %s", dns.TypeToString[recordType], hostname)
-}
-
-// CaptureScreenshot takes a screenshot of the provided URL and returns the result.
-func (s *Screener) CaptureScreenshot(parsedURL *url.URL) (*Result, error) {
-	var result = &Result{}
-
-	captureURL := parsedURL.String()
-	result.TargetURL = captureURL
-	contextTag := fmt.Sprintf("[capture=%s]", captureURL)
-
-	if parsedURL.Scheme == "" {
-		return nil, fmt.Errorf("no URL scheme provided; expected http or https")
-	}
-
-	var err error
-	result.Resolver, err = s.tryResolvers(parsedURL.Hostname())
-	if err != nil {
-		log.Warnf("%s %v", contextTag, err)
-		return nil, err
-	}
-
-	if s.CaptureOptions.DelayBetweenCapture > 0 {
-		log.Debugf("%s Delay between captures: waiting %ds", contextTag, s.CaptureOptions.DelayBetweenCapture)
-		time.Sleep(time.Duration(s.CaptureOptions.DelayBetweenCapture) * time.Second)
+	if s.CaptureOptions.BlockPrivate {
+		if err := checkDestination(parsedURL.Hostname(), result.DNS.IPs()); err != nil {
+			log.Debugf("%s %v", contextTag, err)
+			result.Error = err
+			return result, err
+		}
 	}
 
 	if !strings.HasSuffix(parsedURL.Path, "/") && !urlutil.HasFileExtension(parsedURL.Path) {
@@ -225,6 +222,7 @@ func (s *Screener) CaptureScreenshot(parsedURL *url.URL) (*Result, error) {
 		captureURL = parsedURL.String()
 	}
 
+	// Checked before waiting so skipped duplicates don't use up the rate limits
 	if s.isVisited(captureURL) {
 		log.Warnf("%s Skipping %s as it has already been visited", contextTag, captureURL)
 		return nil, nil
@@ -232,7 +230,20 @@ func (s *Screener) CaptureScreenshot(parsedURL *url.URL) (*Result, error) {
 		log.Debugf("%s Attempting capture on %s", contextTag, captureURL)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.CaptureOptions.Timeout)*time.Second)
+	release, err := s.rateLimiter().Wait(parent, parsedURL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if s.CaptureOptions.DelayBetweenCapture > 0 {
+		log.Debugf("%s Delay between captures: waiting %ds", contextTag, s.CaptureOptions.DelayBetweenCapture)
+		if err := sleepContext(parent, time.Duration(s.CaptureOptions.DelayBetweenCapture)*time.Second); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(parent, time.Duration(s.CaptureOptions.Timeout)*time.Second)
 	defer cancel()
 
 	path, _ := launcher.LookPath()
@@ -258,6 +269,24 @@ func (s *Screener) CaptureScreenshot(parsedURL *url.URL) (*Result, error) {
 		l.Set("proxy-server", s.CaptureOptions.Proxy)
 	}
 
+	// Chrome resolves hosts through the system DNS, so with custom resolvers it
+	// is sent through the guard proxy, which connects to the addresses they return
+	var proxy *guardProxy
+	if s.CaptureOptions.BlockPrivate || (s.pool() != nil && s.CaptureOptions.Proxy == "") {
+		proxy, err = s.startGuardProxy(contextTag, s.CaptureOptions.BlockPrivate)
+		if err != nil {
+			return nil, fmt.Errorf("error starting guard proxy: %w", err)
+		}
+		defer proxy.Close()
+
+		// Route everything through the guard, including loopback
+		l.Set("proxy-server", proxy.URL())
+		l.Set("proxy-bypass-list", "<-loopback>")
+		if s.CaptureOptions.BlockPrivate {
+			l.Set("force-webrtc-ip-handling-policy", "disable_non_proxied_udp")
+		}
+	}
+
 	browserURL := l.MustLaunch()
 	browser := rod.New().ControlURL(browserURL).MustConnect()
 	defer browser.MustClose()
@@ -278,11 +307,23 @@ func (s *Screener) CaptureScreenshot(parsedURL *url.URL) (*Result, error) {
 		}
 	}
 
+	guard, err := s.guardRequests(ctx, page, proxy, contextTag)
+	if err != nil {
+		return nil, fmt.Errorf("error enabling request interception for %s: %w", captureURL, err)
+	}
+
 	var e proto.NetworkResponseReceived
 	wait := page.WaitEvent(&e)
 
 	log.Debugf("%s Navigating to %q", contextTag, captureURL)
 	if err := page.Context(ctx).Navigate(captureURL); err != nil {
+		if err := guard.Err(); err != nil {
+			result.Error = err
+			return result, err
+		}
+		if !final && s.CaptureOptions.Retry.retries(retryCategory(nil, err)) {
+			return nil, fmt.Errorf("navigation to %s failed: %w", captureURL, err)
+		}
 		log.Warnf("%s Navigation failed for %q: %v, attempting screenshot anyway", contextTag, captureURL, err)
 	}
 
@@ -300,6 +341,10 @@ func (s *Screener) CaptureScreenshot(parsedURL *url.URL) (*Result, error) {
 		log.Warnf("%s Timed out waiting for network response for %q", contextTag, captureURL)
 	}
 
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+
 	if s.CaptureOptions.IgnoreRedirects && page.MustInfo().URL != captureURL {
 		log.Warnf("%s Not following redirects as --ignore-redirects flag is set", contextTag)
 		return nil, nil
@@ -307,16 +352,36 @@ func (s *Screener) CaptureScreenshot(parsedURL *url.URL) (*Result, error) {
 
 	log.Debugf("%s Waiting for page load", contextTag)
 	if err := page.Context(ctx).WaitLoad(); err != nil {
+		if parent.Err() != nil {
+			return nil, parent.Err()
+		}
 		return nil, fmt.Errorf("%s timed out after %v: %w", time.Duration(s.CaptureOptions.Timeout)*time.Second, captureURL, err)
 	}
 	log.Debugf("%s Page load completed: finalURL=%q", contextTag, page.MustInfo().URL)
 
+	if err := guard.Err(); err != nil {
+		result.Error = err
+		return result, err
+	}
+	if n := guard.Blocked(); n > 0 {
+		log.Debugf("%s Blocked %d out of scope or forbidden requests", contextTag, n)
+	}
+
 	if s.CaptureOptions.DelayBeforeCapture > 0 {
 		log.Debugf("%s Delay before capture: waiting %ds", contextTag, s.CaptureOptions.DelayBeforeCapture)
-		time.Sleep(time.Duration(s.CaptureOptions.DelayBeforeCapture) * time.Second)
+		if err := sleepContext(parent, time.Duration(s.CaptureOptions.DelayBeforeCapture)*time.Second); err != nil {
+			return nil, err
+		}
 	}
 
-	result.LandingURL = page.MustInfo().URL
+	info := page.MustInfo()
+	result.LandingURL = info.URL
+	result.Title = info.Title
+	result.CapturedAt = time.Now()
+	result.Viewport = fmt.Sprintf("%dx%d", s.CaptureOptions.CaptureWidth, s.CaptureOptions.CaptureHeight)
+	if s.CaptureOptions.CaptureFull {
+		result.Viewport += "-full"
+	}
 	log.Debugf("%s Capturing screenshot (full=%t)", contextTag, s.CaptureOptions.CaptureFull)
 	result.Image, err = page.Screenshot(s.CaptureOptions.CaptureFull, nil)
 	if err != nil {
@@ -330,6 +395,8 @@ func (s *Screener) CaptureScreenshot(parsedURL *url.URL) (*Result, error) {
 	} else {
 		log.Infof("%s Captured screenshot %q", contextTag, captureURL)
 	}
+	digest := sha256.Sum256(result.Image)
+	result.RawSHA256 = hex.EncodeToString(digest[:])
 
 	if sliceutil.Contains(s.CaptureOptions.IgnoreStatusCodes, e.Response.Status) {
 		log.Warnf("%s Ignoring %q as it returned status code %d", contextTag, captureURL, e.Response.Status)
@@ -337,64 +404,60 @@ func (s *Screener) CaptureScreenshot(parsedURL *url.URL) (*Result, error) {
 	}
 
 	result.StatusCode = e.Response.Status
-	s.addVisited(captureURL)
+	result.Headers = make(map[string]string, len(e.Response.Headers))
+	for k, v := range e.Response.Headers {
+		result.Headers[k] = v.String()
+	}
+
+	if final || !s.CaptureOptions.Retry.retries(retryCategory(result, nil)) {
+		s.addVisited(captureURL)
+	}
 
 	return result, nil
 }
 
-// SaveImageToFolder saves the image to the provided path.
+// SaveImageToFolder saves the image to the provided path, named after the
+// scheme, host and path of the target as it always has been, e.g.
+// https_example.com-8080_blog_post.png. Use SaveImage to name files with a
+// Layout instead.
 func (result Result) SaveImageToFolder(localFilePath string) (filename string, err error) {
 	if len(result.Image) == 0 {
-		return "", err
+		return "", nil
 	}
 
-	err = os.MkdirAll(localFilePath, os.ModePerm)
+	name, err := result.legacyFilename()
 	if err != nil {
 		return "", err
 	}
 
-	parsedTargetURL, err := url.Parse(result.TargetURL)
+	storage := &FileStorage{Dir: localFilePath}
+	return storage.Put(context.Background(), name, result.Image, nil)
+}
+
+// legacyFilename is the name SaveImageToFolder gives the image
+func (result Result) legacyFilename() (string, error) {
+	target, err := url.Parse(result.TargetURL)
 	if err != nil {
 		return "", err
 	}
 
-	parsedRedirectURL, err := url.Parse(result.LandingURL)
+	landing, err := url.Parse(result.LandingURL)
 	if err != nil {
 		return "", err
 	}
-
-	parsedWriteURL := parsedTargetURL
-
-	if parsedTargetURL.Path == "" {
-		parsedWriteURL.Path = ""
-	}
-
-	if parsedRedirectURL.Scheme != "" {
-		parsedWriteURL.Scheme = parsedRedirectURL.Scheme
+	if landing.Scheme != "" {
+		target.Scheme = landing.Scheme
 	}
 
-	if (parsedWriteURL.Scheme == "http" || parsedWriteURL.Scheme == "https") && parsedWriteURL.Port() == "80" || parsedWriteURL.Port() == "443" {
-		parsedWriteURL.Host = strings.Split(parsedWriteURL.Host, ":")[0]
+	host := target.Host
+	if port := target.Port(); port == "80" || port == "443" {
+		host = target.Hostname()
 	}
 
-	filename = parsedWriteURL.Scheme + "_" + parsedWriteURL.Host + parsedWriteURL.Path
-	filename = strings.TrimSuffix(filename, "/")
+	filename := strings.TrimSuffix(target.Scheme+"_"+host+target.Path, "/")
 	filename = strings.ReplaceAll(filename, "/", "_")
 	filename = strings.ReplaceAll(filename, ":", "-")
-	filename = filepath.Join(localFilePath, filename+".png")
-
-	file, err := os.Create(strings.ToLower(filename))
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	_, err = file.Write(result.Image)
-	if err != nil {
-		return "", err
-	}
-
-	return filename, nil
+	return strings.ToLower(filename) + ".png", nil
 }
 
 // IsSimilarToAny checks if the image is a duplicate of any of the images in the results slice
@@ -418,84 +481,51 @@ func (result Result) IsSimilarToAny(results []Result, similarityThreshold int) b
 	return false
 }
 
-// AddTextToImage adds text to bottom of the image
+// AddTextToImage adds the origin of rawURL to the bottom of the image
 func (imgB Image) AddTextToImage(rawURL string) ([]byte, error) {
-	parsedURL, err := url.Parse(rawURL)
-	if err != nil {
+	if _, err := url.Parse(rawURL); err != nil {
 		return nil, fmt.Errorf("failed to parse URL: %w", err)
 	}
 
-	host := parsedURL.Host
-	if strings.Contains(host, ":") {
-		hostWithoutPort, port, _ := strings.Cut(host, ":")
-		if (parsedURL.Scheme == "http" && port == "80") || (parsedURL.Scheme == "https" && port == "443") {
-			host = hostWithoutPort
-		}
-	}
-
-	printURL := parsedURL.Scheme + "://" + host
-
-	img, err := png.Decode(bytes.NewReader(imgB))
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	const padding = 20
-	const borderSize = 1
-
-	w := img.Bounds().Dx()
-	h := img.Bounds().Dy() + padding*2 + borderSize
-	dc := gg.NewContext(w, h)
-
-	dc.DrawImage(img, 0, 0)
-
-	yLine := float64(img.Bounds().Dy())
-	dc.SetColor(color.Black)
-	dc.DrawLine(0, yLine, float64(w), yLine)
-	dc.SetLineWidth(float64(borderSize))
-	dc.Stroke()
-	dc.SetColor(color.White)
-	dc.DrawRectangle(0, yLine, float64(w), float64(padding*2))
-	dc.Fill()
-	dc.SetColor(color.Black)
-	dc.SetFontFace(loadFont())
-	dc.DrawStringAnchored(printURL, float64(w)/2, yLine+float64(padding), 0.2, 0.3)
-
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, dc.Image()); err != nil {
-		return nil, fmt.Errorf("failed to encode image: %w", err)
-	}
-
-	return buf.Bytes(), nil
+	return imgB.drawBanner([]string{printableOrigin(rawURL)}, DefaultAnnotation())
 }
 
 //go:embed assets/Roboto-Medium.ttf
 var fontBytes embed.FS
 
-func loadFont() font.Face {
+func embeddedFont() []byte {
 	fontData, err := fontBytes.ReadFile("assets/Roboto-Medium.ttf")
 	if err != nil {
 		log.Fatalf("Failed to read embedded font: %v", err)
 	}
+	return fontData
+}
 
-	ttFont, err := truetype.Parse(fontData)
-	if err != nil {
-		log.Fatalf("Failed to parse embedded font: %v", err)
-	}
+// sleepContext sleeps for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-	return truetype.NewFace(ttFont, &truetype.Options{
-		Size: 14,
-	})
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (s *Screener) addVisited(str string) {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
 	s.visited[str] = true
+	s.mutex.Unlock()
+
+	if err := s.CaptureOptions.State.Add(str); err != nil {
+		log.Warnf("Error recording %s in state file: %v", str, err)
+	}
 }
 
 func (s *Screener) isVisited(str string) bool {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	return s.visited[str]
+	return s.visited[str] || s.CaptureOptions.State.Has(str)
 }
@@ -2,8 +2,14 @@ package screener
 
 import (
 	_ "embed"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+
+	"github.com/go-rod/rod/lib/launcher"
 )
 
 func TestCaptureScreenshot(t *testing.T) {
@@ -41,3 +47,29 @@ func TestCaptureScreenshot(t *testing.T) {
 		})
 	}
 }
+
+func TestCaptureScreenshotWithCustomResolver(t *testing.T) {
+	if _, found := launcher.LookPath(); !found {
+		t.Skip("no browser installed")
+	}
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<title>only custom</title>"))
+	}))
+	defer target.Close()
+
+	// The system resolver doesn't know the name, so the browser must use the
+	// addresses from the custom resolver
+	screener := NewScreener()
+	screener.CaptureOptions.CustomResolvers = []string{startLocalResolver(t, "only-custom.test")}
+
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(target.URL, "http://"))
+	parsedURL, _ := url.Parse("http://only-custom.test:" + port + "/")
+	result, err := screener.CaptureScreenshot(parsedURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || len(result.Image) == 0 || result.StatusCode != 200 || result.Title != "only custom" {
+		t.Fatalf("expected the page behind the custom name to be captured, got %+v", result)
+	}
+}
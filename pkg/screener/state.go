@@ -0,0 +1,106 @@
+package screener
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// StateFile is a persistent set of completed targets, one URL per line, with
+// https assumed for URLs without a scheme. Entries are appended as they are
+// added so the file survives the process being killed.
+type StateFile struct {
+	mutex   sync.Mutex
+	path    string
+	file    *os.File
+	entries map[string]bool
+}
+
+// OpenStateFile loads the entries in path, creating the file if needed, and
+// opens it for appending
+func OpenStateFile(path string) (*StateFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &StateFile{path: path, file: f, entries: make(map[string]bool)}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			state.entries[stateKey(line)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error reading state file %s: %w", path, err)
+	}
+
+	return state, nil
+}
+
+// Path returns the path of the state file
+func (s *StateFile) Path() string {
+	return s.path
+}
+
+// Len returns the number of entries
+func (s *StateFile) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.entries)
+}
+
+// Has reports whether rawURL, once normalized, is in the state file
+func (s *StateFile) Has(rawURL string) bool {
+	if s == nil {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.entries[stateKey(rawURL)]
+}
+
+// Add records rawURL, appending it to the file if it is new
+func (s *StateFile) Add(rawURL string) error {
+	if s == nil {
+		return nil
+	}
+
+	key := stateKey(rawURL)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.entries[key] {
+		return nil
+	}
+	s.entries[key] = true
+
+	_, err := s.file.WriteString(key + "\n")
+	return err
+}
+
+// stateKey normalizes rawURL, defaulting to https when it has no scheme
+func stateKey(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	return NormalizeURL(rawURL)
+}
+
+// Close closes the underlying file
+func (s *StateFile) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.file.Close()
+}
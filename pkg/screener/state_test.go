@@ -0,0 +1,70 @@
+package screener
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "screener.state")
+	if err := os.WriteFile(path, []byte("# screener state\nexample.com\nHTTPS://Example.org:443/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := OpenStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Len() != 2 || !state.Has("example.com") || !state.Has("https://example.org") {
+		t.Fatalf("expected existing entries to be loaded, got %d", state.Len())
+	}
+
+	if err := state.Add("https://example.net/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Add("https://EXAMPLE.net"); err != nil {
+		t.Fatal(err)
+	}
+	state.Close()
+
+	// Entries survive reopening and are not duplicated
+	state, err = OpenStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	if !state.Has("https://example.net") || state.Len() != 3 {
+		t.Fatalf("expected appended entry to persist, got %d entries", state.Len())
+	}
+
+	// Targets without a scheme match the https URLs captured for them
+	if !state.Has("example.net") || !state.Has("https://example.com/") || state.Has("http://example.com") {
+		t.Fatal("expected targets without a scheme to default to https")
+	}
+}
+
+func TestVisitedBackedByStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "screener.state")
+
+	state, err := OpenStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewScreener()
+	s.CaptureOptions.State = state
+	s.addVisited("https://example.com/")
+	state.Close()
+
+	state, err = OpenStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	restarted := NewScreener()
+	restarted.CaptureOptions.State = state
+	if !restarted.isVisited("https://example.com/") {
+		t.Fatal("expected visited URL to survive a restart")
+	}
+}
@@ -0,0 +1,136 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Storage stores capture artifacts such as screenshots. Implementations are
+// safe for concurrent use.
+type Storage interface {
+	// Put stores data under name, a relative slash separated path, along with
+	// metadata, and returns a URI for the stored artifact
+	Put(ctx context.Context, name string, data []byte, metadata map[string]string) (string, error)
+	// Close flushes pending writes and releases resources
+	Close() error
+}
+
+// NameChecker is implemented by storage that can tell whether an artifact is
+// already stored under name, so that a Layout doesn't overwrite it
+type NameChecker interface {
+	Exists(ctx context.Context, name string) (bool, error)
+}
+
+// OpenStorage returns the storage for an output location: s3://bucket/prefix
+// for S3-compatible object storage, a path ending in .zip, .tar, .tar.gz or
+// .tgz for an archive, and any other path for a local folder
+func OpenStorage(output string) (Storage, error) {
+	switch {
+	case strings.HasPrefix(output, "s3://"):
+		return NewS3StorageFromEnv(output)
+	case isArchivePath(output):
+		return NewArchiveStorage(output)
+	default:
+		return &FileStorage{Dir: output}, nil
+	}
+}
+
+// IsFolderOutput reports whether OpenStorage stores artifacts for output in a
+// local folder
+func IsFolderOutput(output string) bool {
+	return !strings.HasPrefix(output, "s3://") && !isArchivePath(output)
+}
+
+// FileStorage stores artifacts in a local folder. Metadata isn't stored, as
+// screenshots carry their own (see Image.EmbedMetadata).
+type FileStorage struct {
+	Dir string
+}
+
+// Put writes data to Dir/name, creating directories as needed
+func (s *FileStorage) Put(ctx context.Context, name string, data []byte, metadata map[string]string) (string, error) {
+	filename := filepath.Join(s.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		return "", err
+	}
+
+	return filename, nil
+}
+
+// Exists reports whether Dir/name exists
+func (s *FileStorage) Exists(ctx context.Context, name string) (bool, error) {
+	_, err := os.Stat(filepath.Join(s.Dir, filepath.FromSlash(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Close does nothing as files are written as they are put
+func (s *FileStorage) Close() error {
+	return nil
+}
+
+// storageMetadata describes result for storage backends
+func (result Result) storageMetadata() map[string]string {
+	metadata := map[string]string{
+		"target-url": result.TargetURL,
+	}
+	if result.LandingURL != "" {
+		metadata["landing-url"] = result.LandingURL
+	}
+	if result.StatusCode != 0 {
+		metadata["status"] = strconv.Itoa(result.StatusCode)
+	}
+	if !result.CapturedAt.IsZero() {
+		metadata["captured-at"] = result.CapturedAt.UTC().Format(time.RFC3339)
+	}
+	for k, v := range result.Metadata {
+		metadata[k] = v
+	}
+	return metadata
+}
+
+// Store puts the image in storage under the name given by layout, and the
+// thumbnail, if any, next to it with a .thumb.png extension. The URIs are
+// recorded in the result and the image URI is returned. Nothing is stored for
+// results without an image.
+func (result *Result) Store(ctx context.Context, storage Storage, layout *Layout) (string, error) {
+	if len(result.Image) == 0 {
+		return "", nil
+	}
+
+	name, err := layout.NameIn(ctx, storage, *result, ".png")
+	if err != nil {
+		return "", err
+	}
+	name = filepath.ToSlash(name)
+	metadata := result.storageMetadata()
+
+	uri, err := storage.Put(ctx, name, result.Image, metadata)
+	if err != nil {
+		return "", fmt.Errorf("error storing %s: %w", name, err)
+	}
+	result.ImageURI = uri
+
+	if len(result.Thumbnail) > 0 {
+		thumbName := strings.TrimSuffix(name, ".png") + ".thumb.png"
+		thumbURI, err := storage.Put(ctx, thumbName, result.Thumbnail, metadata)
+		if err != nil {
+			return "", fmt.Errorf("error storing %s: %w", thumbName, err)
+		}
+		result.ThumbnailURI = thumbURI
+	}
+
+	return uri, nil
+}
@@ -0,0 +1,235 @@
+package screener
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxExpansion is the default cap on URLs generated from a single target
+const DefaultMaxExpansion = 65536
+
+// ExpandOptions controls how targets are expanded into URLs
+type ExpandOptions struct {
+	Ports        []int // ports combined with every host that has no port of its own
+	MaxExpansion int   // maximum number of URLs a single target may expand to
+}
+
+// ExpandTarget expands CIDRs (10.0.0.0/24, without a scheme), IP ranges (10.0.0.1-10.0.0.9 or
+// 10.0.0.1-9) and port lists (host:80-90, or ExpandOptions.Ports) into URLs.
+// Expanded targets without a scheme are probed over both http and https,
+// except on ports 80 and 443. Targets that need no expansion are returned as-is.
+func ExpandTarget(target string, opts ExpandOptions) ([]string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, nil
+	}
+
+	maxExpansion := opts.MaxExpansion
+	if maxExpansion <= 0 {
+		maxExpansion = DefaultMaxExpansion
+	}
+
+	scheme, rest, hasScheme := strings.Cut(target, "://")
+	if !hasScheme {
+		scheme, rest = "", target
+	}
+
+	// With a scheme, anything after a slash is a path rather than a netmask
+	var hostSpec, portSpec, path string
+	if _, _, err := net.ParseCIDR(rest); err == nil && !hasScheme {
+		hostSpec = rest
+	} else {
+		hostPort := rest
+		if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+			hostPort, path = rest[:i], rest[i:]
+		}
+		hostSpec, portSpec = splitHostPortSpec(hostPort)
+	}
+
+	hosts, expanded, err := expandHosts(hostSpec, maxExpansion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %q: %w", target, err)
+	}
+
+	var ports []int
+	if portSpec != "" {
+		if ports, err = ParsePorts(portSpec); err != nil {
+			return nil, fmt.Errorf("invalid target %q: %w", target, err)
+		}
+		expanded = expanded || len(ports) > 1
+	} else if len(opts.Ports) > 0 {
+		ports = opts.Ports
+		expanded = true
+	}
+
+	if !expanded {
+		return []string{target}, nil
+	}
+
+	if len(ports) == 0 {
+		ports = []int{0}
+	}
+
+	var perHost int
+	for _, port := range ports {
+		perHost += len(probeSchemes(scheme, port))
+	}
+	if len(hosts)*perHost > maxExpansion {
+		return nil, fmt.Errorf("target %q expands to more than %d URLs", target, maxExpansion)
+	}
+
+	var urls []string
+	for _, host := range hosts {
+		for _, port := range ports {
+			hostPort := host
+			if port != 0 {
+				hostPort = net.JoinHostPort(host, strconv.Itoa(port))
+			} else if strings.Contains(host, ":") {
+				hostPort = "[" + host + "]"
+			}
+
+			for _, s := range probeSchemes(scheme, port) {
+				urls = append(urls, s+"://"+hostPort+path)
+			}
+		}
+	}
+
+	return urls, nil
+}
+
+// ParsePorts parses a comma separated list of ports and port ranges such as "80,443,8000-8010"
+func ParsePorts(spec string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		startStr, endStr, isRange := strings.Cut(part, "-")
+		start, err := parsePort(startStr)
+		if err != nil {
+			return nil, err
+		}
+
+		end := start
+		if isRange {
+			if end, err = parsePort(endStr); err != nil {
+				return nil, err
+			}
+			if end < start {
+				return nil, fmt.Errorf("invalid port range %q", part)
+			}
+		}
+
+		for p := start; p <= end; p++ {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports in %q", spec)
+	}
+
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
+
+// splitHostPortSpec splits host:ports, leaving bare IPv6 addresses intact
+func splitHostPortSpec(hostPort string) (string, string) {
+	if strings.HasPrefix(hostPort, "[") {
+		host, ports, _ := strings.Cut(strings.TrimPrefix(hostPort, "["), "]")
+		return host, strings.TrimPrefix(ports, ":")
+	}
+
+	if strings.Count(hostPort, ":") == 1 {
+		host, ports, _ := strings.Cut(hostPort, ":")
+		return host, ports
+	}
+
+	return hostPort, ""
+}
+
+// expandHosts expands a CIDR or IP range into addresses. The boolean reports
+// whether hostSpec described more than a single host.
+func expandHosts(hostSpec string, limit int) ([]string, bool, error) {
+	if prefix, err := netip.ParsePrefix(hostSpec); err == nil {
+		hostBits := prefix.Addr().BitLen() - prefix.Bits()
+		if hostBits >= 31 || 1<<hostBits > limit {
+			return nil, false, fmt.Errorf("CIDR expands to more than %d hosts", limit)
+		}
+		return addrRange(prefix.Masked().Addr(), 1<<hostBits), true, nil
+	}
+
+	startStr, endStr, isRange := strings.Cut(hostSpec, "-")
+	if !isRange {
+		return []string{hostSpec}, false, nil
+	}
+
+	start, err := netip.ParseAddr(startStr)
+	if err != nil {
+		// Hyphens are valid in hostnames
+		return []string{hostSpec}, false, nil
+	}
+
+	end, err := netip.ParseAddr(endStr)
+	if err != nil && start.Is4() {
+		// Short form: 10.0.0.1-50 replaces the last octet
+		octet, convErr := strconv.Atoi(endStr)
+		if convErr != nil || octet < 0 || octet > 255 {
+			return nil, false, fmt.Errorf("invalid IP range %q", hostSpec)
+		}
+		b := start.As4()
+		b[3] = byte(octet)
+		end, err = netip.AddrFrom4(b), nil
+	}
+	if err != nil || start.BitLen() != end.BitLen() || end.Less(start) {
+		return nil, false, fmt.Errorf("invalid IP range %q", hostSpec)
+	}
+
+	var n int
+	for a := start; a.Compare(end) <= 0 && a.IsValid(); a = a.Next() {
+		if n++; n > limit {
+			return nil, false, fmt.Errorf("IP range expands to more than %d hosts", limit)
+		}
+	}
+
+	return addrRange(start, n), true, nil
+}
+
+func addrRange(start netip.Addr, n int) []string {
+	addrs := make([]string, 0, n)
+	for a := start; len(addrs) < n && a.IsValid(); a = a.Next() {
+		addrs = append(addrs, a.String())
+	}
+	return addrs
+}
+
+// probeSchemes returns the schemes to probe for a port when none was given
+func probeSchemes(scheme string, port int) []string {
+	switch {
+	case scheme != "":
+		return []string{scheme}
+	case port == 80:
+		return []string{"http"}
+	case port == 443:
+		return []string{"https"}
+	default:
+		return []string{"https", "http"}
+	}
+}
@@ -0,0 +1,85 @@
+package screener
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		opts   ExpandOptions
+		want   []string
+	}{
+		{target: "example.com", want: []string{"example.com"}},
+		{target: "example.com:8080", want: []string{"example.com:8080"}},
+		{target: "my-host.example.com", want: []string{"my-host.example.com"}},
+		{target: "https://example.com/path", want: []string{"https://example.com/path"}},
+		{target: "http://10.0.0.1/24", want: []string{"http://10.0.0.1/24"}},
+		{target: "https://203.0.113.5/8", want: []string{"https://203.0.113.5/8"}},
+		{
+			target: "10.0.0.0/30",
+			want: []string{
+				"https://10.0.0.0", "http://10.0.0.0", "https://10.0.0.1", "http://10.0.0.1",
+				"https://10.0.0.2", "http://10.0.0.2", "https://10.0.0.3", "http://10.0.0.3",
+			},
+		},
+		{
+			target: "10.0.0.1-2",
+			opts:   ExpandOptions{Ports: []int{80, 443, 8080}},
+			want: []string{
+				"http://10.0.0.1:80", "https://10.0.0.1:443", "https://10.0.0.1:8080", "http://10.0.0.1:8080",
+				"http://10.0.0.2:80", "https://10.0.0.2:443", "https://10.0.0.2:8080", "http://10.0.0.2:8080",
+			},
+		},
+		{
+			target: "10.0.0.254-10.0.1.1",
+			opts:   ExpandOptions{Ports: []int{80}},
+			want:   []string{"http://10.0.0.254:80", "http://10.0.0.255:80", "http://10.0.1.0:80", "http://10.0.1.1:80"},
+		},
+		{
+			target: "http://example.com:8000-8002/admin",
+			want:   []string{"http://example.com:8000/admin", "http://example.com:8001/admin", "http://example.com:8002/admin"},
+		},
+		{
+			target: "[2001:db8::1]:80-81",
+			want:   []string{"http://[2001:db8::1]:80", "https://[2001:db8::1]:81", "http://[2001:db8::1]:81"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			got, err := ExpandTarget(tt.target, tt.opts)
+			if err != nil {
+				t.Fatalf("ExpandTarget(%q) failed: %v", tt.target, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ExpandTarget(%q) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandTargetLimits(t *testing.T) {
+	for _, target := range []string{"10.0.0.0/8", "10.0.0.0/24", "10.0.0.1-10.0.1.1", "10.0.0.9-1", "host:90-80"} {
+		if _, err := ExpandTarget(target, ExpandOptions{MaxExpansion: 100}); err == nil {
+			t.Errorf("expected %q to be rejected", target)
+		}
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	got, err := ParsePorts("80, 443,8000-8002,443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{80, 443, 8000, 8001, 8002}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParsePorts = %v, want %v", got, want)
+	}
+
+	for _, spec := range []string{"0", "65536", "http", "90-80", ""} {
+		if _, err := ParsePorts(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package screener
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// Thumbnail returns the image scaled down to maxWidth with Catmull-Rom
+// resampling, keeping its aspect ratio. Images no wider than maxWidth are
+// returned as they are.
+func (imgB Image) Thumbnail(maxWidth int) (Image, error) {
+	if maxWidth < 1 {
+		return nil, fmt.Errorf("invalid thumbnail width %d", maxWidth)
+	}
+
+	img, err := png.Decode(bytes.NewReader(imgB))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() <= maxWidth {
+		return imgB, nil
+	}
+
+	height := max(1, bounds.Dy()*maxWidth/bounds.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, maxWidth, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,51 @@
+package screener
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	thumb, err := testPNG(t, 1280, 3000, color.White).Thumbnail(320)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 320 || img.Bounds().Dy() != 750 {
+		t.Fatalf("expected 320x750 thumbnail, got %v", img.Bounds())
+	}
+
+	// Narrow images are kept as they are
+	small := testPNG(t, 100, 100, color.White)
+	if thumb, err := small.Thumbnail(320); err != nil || !bytes.Equal(thumb, small) {
+		t.Fatalf("expected small image unchanged: %v", err)
+	}
+
+	if _, err := small.Thumbnail(0); err == nil {
+		t.Fatal("expected error for zero width")
+	}
+}
+
+func TestStoreThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	layout, _ := NewLayout("", false, CollisionSuffix)
+	result := Result{TargetURL: "https://example.com", Image: Image("png"), Thumbnail: Image("thumb")}
+
+	if _, err := result.Store(context.Background(), &FileStorage{Dir: dir}, layout); err != nil {
+		t.Fatal(err)
+	}
+	if result.ImageURI != filepath.Join(dir, "https_example.com.png") || result.ThumbnailURI != filepath.Join(dir, "https_example.com.thumb.png") {
+		t.Fatalf("unexpected URIs %q %q", result.ImageURI, result.ThumbnailURI)
+	}
+	if data, err := os.ReadFile(result.ThumbnailURI); err != nil || string(data) != "thumb" {
+		t.Fatalf("expected thumbnail to be written: %v", err)
+	}
+}
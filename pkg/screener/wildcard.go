@@ -0,0 +1,108 @@
+package screener
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/root4loot/goutils/log"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Wildcard modes for captureOptions.WildcardMode
+const (
+	WildcardSkip = "skip" // do not capture hosts answered by a wildcard record
+	WildcardTag  = "tag"  // capture them but set Result.Wildcard
+)
+
+// ErrWildcard is returned when a host only resolves through a wildcard DNS record
+var ErrWildcard = errors.New("host resolves to a wildcard DNS record")
+
+// wildcardProbes is the number of random labels resolved per parent domain
+const wildcardProbes = 2
+
+type wildcardEntry struct {
+	once sync.Once
+	ips  map[string]bool
+}
+
+// isWildcard reports whether every address hostname resolved to is also
+// returned for random labels under one of its parent domains. Public suffixes
+// such as co.uk are not probed, as they are not under the owner's control.
+func (s *Screener) isWildcard(hostname string, ips []string) bool {
+	if net.ParseIP(hostname) != nil || len(ips) == 0 {
+		return false
+	}
+
+	hostname = strings.TrimSuffix(hostname, ".")
+	suffix, _ := publicsuffix.PublicSuffix(strings.ToLower(hostname))
+	labels := strings.Split(hostname, ".")
+	suffixLabels := strings.Count(suffix, ".") + 1
+	for i := 1; i < len(labels)-suffixLabels; i++ {
+		parent := strings.Join(labels[i:], ".")
+		wildcardIPs := s.wildcardIPs(parent)
+		if len(wildcardIPs) == 0 {
+			continue
+		}
+
+		matched := true
+		for _, ip := range ips {
+			if !wildcardIPs[ip] {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			log.Debugf("[wildcard=*.%s] %s matches wildcard answer", parent, hostname)
+			return true
+		}
+	}
+
+	return false
+}
+
+// wildcardIPs returns the addresses random labels under parent resolve to,
+// probing each parent only once per Screener
+func (s *Screener) wildcardIPs(parent string) map[string]bool {
+	s.mutex.Lock()
+	if s.wildcards == nil {
+		s.wildcards = make(map[string]*wildcardEntry)
+	}
+	entry, ok := s.wildcards[parent]
+	if !ok {
+		entry = &wildcardEntry{}
+		s.wildcards[parent] = entry
+	}
+	s.mutex.Unlock()
+
+	entry.once.Do(func() {
+		for i := 0; i < wildcardProbes; i++ {
+			_, records, err := s.resolveHost(randomLabel() + "." + parent)
+			if err != nil {
+				continue
+			}
+			if entry.ips == nil {
+				entry.ips = make(map[string]bool)
+			}
+			for _, ip := range records.IPs() {
+				entry.ips[ip] = true
+			}
+		}
+
+		if len(entry.ips) > 0 {
+			log.Debugf("[wildcard=*.%s] Detected wildcard DNS record", parent)
+		}
+	})
+
+	return entry.ips
+}
+
+func randomLabel() string {
+	b := make([]byte, 10)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
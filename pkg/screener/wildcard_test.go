@@ -0,0 +1,64 @@
+package screener
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestIsWildcard(t *testing.T) {
+	// *.wild.example answers 192.0.2.10, tame.example has no wildcard
+	records := map[string]string{
+		"www.wild.example.":    "192.0.2.10",
+		"app.wild.example.":    "192.0.2.20",
+		"www.tame.example.":    "192.0.2.10",
+		"deep.a.wild.example.": "192.0.2.10",
+		"shop.co.uk.":          "192.0.2.30",
+	}
+
+	resolver := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := &dns.Msg{}
+		m.SetReply(r)
+		name := r.Question[0].Name
+
+		ip, ok := records[name]
+		if !ok && strings.HasSuffix(name, ".wild.example.") {
+			ip, ok = "192.0.2.10", true
+		}
+		// A public suffix answering for any label must not count as a wildcard
+		if !ok && strings.HasSuffix(name, ".co.uk.") {
+			ip, ok = "192.0.2.30", true
+		}
+
+		if !ok {
+			m.SetRcode(r, dns.RcodeNameError)
+		} else if r.Question[0].Qtype == dns.TypeA {
+			rr, _ := dns.NewRR(name + " 60 IN A " + ip)
+			m.Answer = append(m.Answer, rr)
+		}
+		w.WriteMsg(m)
+	})
+
+	s := NewScreener()
+	s.CaptureOptions.CustomResolvers = []string{resolver}
+
+	tests := map[string]bool{
+		"www.wild.example":    true,
+		"deep.a.wild.example": true,
+		"app.wild.example":    false,
+		"www.tame.example":    false,
+		"192.0.2.10":          false,
+		"shop.co.uk":          false,
+	}
+
+	for host, want := range tests {
+		_, records, err := s.resolveHost(host)
+		if err != nil {
+			t.Fatalf("resolveHost(%s) failed: %v", host, err)
+		}
+		if got := s.isWildcard(host, records.IPs()); got != want {
+			t.Errorf("isWildcard(%s) = %t, want %t", host, got, want)
+		}
+	}
+}